@@ -0,0 +1,253 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/discord"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var discordCmd = &cobra.Command{
+	Use:   "discord",
+	Short: "Send a message to a discord webhook",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureDiscordConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureDiscordCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		title, _ := cmd.Flags().GetString("title")
+		message, _ := cmd.Flags().GetString("message")
+		priority, _ := cmd.Flags().GetString("priority")
+
+		if err := sendDiscordMessages(cmd, title, message, "", discordPriorityColor(ntfy.Priority(priority)), nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+var discordWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send the result to a discord webhook",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureDiscordConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureDiscordWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending message")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending message")
+			return
+		}
+
+		color := discord.ColorSuccess
+		if result.Failed() {
+			color = discord.ColorFailure
+		}
+
+		output := ""
+		if !onlyMessage {
+			output = result.Output
+		}
+		if result.ExitCode == -1 {
+			// the command could not be started, show why instead of an exit code
+			message = result.Message(message, true)
+		}
+
+		fields := []discord.Field{
+			{Name: "Host", Value: result.Host, Inline: true},
+			{Name: "Exit code", Value: strconv.Itoa(result.ExitCode), Inline: true},
+			{Name: "Duration", Value: result.Duration.Round(time.Millisecond).String(), Inline: true},
+		}
+
+		title, _ := cmd.Flags().GetString("title")
+		if title == "" {
+			title = result.Command()
+		}
+
+		if err := sendDiscordMessages(cmd, title, message, output, color, fields); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+// sendDiscordMessages sends the message as embed, split over several messages
+// if it is too long, followed by the output as code blocks or as attachment.
+func sendDiscordMessages(cmd *cobra.Command, title, message, output string, color int, fields []discord.Field) error {
+	webhook, _ := cmd.Flags().GetString("webhook")
+	url, _ := cmd.Flags().GetString("url")
+	icon, _ := cmd.Flags().GetString("icon")
+	username, _ := cmd.Flags().GetString("username")
+	avatar, _ := cmd.Flags().GetString("avatar")
+	attachOutput, _ := cmd.Flags().GetBool("attach-output")
+	maxMessages, _ := cmd.Flags().GetInt("max-messages")
+
+	var messages []*discord.Message
+	descriptions := discord.Chunk(message, discord.DescriptionLimit)
+	if len(descriptions) == 0 {
+		descriptions = []string{""}
+	}
+	for i, description := range descriptions {
+		embed := discord.Embed{Description: description, Color: color}
+		if i == 0 {
+			if titles := discord.Chunk(title, discord.TitleLimit); len(titles) > 0 {
+				embed.Title = titles[0]
+			}
+			embed.Url = url
+			if icon != "" {
+				embed.Thumbnail = &discord.Thumbnail{Url: icon}
+			}
+		}
+		if i == len(descriptions)-1 {
+			embed.Fields = fields
+		}
+		messages = append(messages, &discord.Message{Embeds: []discord.Embed{embed}})
+	}
+
+	if output != "" && attachOutput {
+		messages[len(messages)-1].File = &discord.File{Name: "output.txt", Data: []byte(output)}
+	} else if output != "" {
+		blocks := discord.CodeBlocks(output)
+		if maxMessages > 0 && len(blocks) > maxMessages {
+			slog.Debug("Output too long, only sending the end", "messages", len(blocks))
+			blocks = blocks[len(blocks)-maxMessages:]
+		}
+		for _, block := range blocks {
+			messages = append(messages, &discord.Message{Content: block})
+		}
+	}
+
+	for _, m := range messages {
+		m.Username = username
+		m.AvatarUrl = avatar
+		if err := discord.SendMessage(m, webhook); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func discordPriorityColor(priority ntfy.Priority) int {
+	switch priority.Level() {
+	case 1:
+		return 0x95a5a6
+	case 2:
+		return 0x3498db
+	case 4:
+		return 0xe67e22
+	case 5:
+		return 0xe74c3c
+	}
+
+	return 0x5865f2
+}
+
+func ensureDiscordConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("webhook") {
+		return errors.New("webhook must be provided")
+	}
+
+	return nil
+}
+
+func ensureDiscordCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureDiscordWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(discordCmd)
+	discordCmd.AddCommand(discordWrapCmd)
+
+	discordCmd.PersistentFlags().StringP("webhook", "w", "", "Discord webhook URL")
+	discordCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	discordCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	discordCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority, sets the embed color (min, low, default, high, max)")
+	discordCmd.PersistentFlags().StringP("url", "U", "", "URL linked from the title")
+	discordCmd.PersistentFlags().String("icon", "", "URL of an image shown as thumbnail")
+	discordCmd.PersistentFlags().String("username", "", "Username to post as")
+	discordCmd.PersistentFlags().String("avatar", "", "URL of the avatar to post with")
+
+	discordWrapCmd.Flags().Bool("fail", false, "Send a message only if the command fails")
+	discordWrapCmd.Flags().Bool("success", false, "Send a message only if the command succeeds")
+	discordWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	discordWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	discordWrapCmd.Flags().Bool("attach-output", false, "Upload the full output as file instead of code blocks")
+	discordWrapCmd.Flags().Int("max-messages", 5, "Maximum number of messages with output, only the end is sent (0 for no limit)")
+}
@@ -0,0 +1,259 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rwxd/notify-me/internal/markdown"
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/email"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+const emailWrapSubject = "[{{ .Status }}] {{ .Result.Command }} on {{ .Host }}"
+
+var emailCmd = &cobra.Command{
+	Use:   "email",
+	Short: "Send an email over SMTP",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureEmailConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureEmailCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		subject, _ := cmd.Flags().GetString("subject")
+		message, _ := cmd.Flags().GetString("message")
+
+		event := notify.NewEvent(subject, message, ntfy.PriorityDefault, nil, "")
+		if err := sendEmail(cmd, event, nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Email sent")
+	},
+}
+
+var emailWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send the result as email",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureEmailConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureEmailWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		subject, _ := cmd.Flags().GetString("subject")
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		attachOutput, _ := cmd.Flags().GetBool("attach-output")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending email")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending email")
+			return
+		}
+
+		if subject == "" {
+			subject = emailWrapSubject
+		}
+
+		var attachments []email.Attachment
+		if attachOutput {
+			if result.Output != "" {
+				attachments = append(attachments, email.Attachment{Name: "output.txt", ContentType: "text/plain; charset=utf-8", Data: []byte(result.Output)})
+			}
+			message = result.Message(message, true)
+		} else {
+			message = result.Message(message, onlyMessage)
+		}
+
+		event := notify.NewEvent(subject, message, ntfy.PriorityDefault, nil, "").WithResult(result)
+		if err := sendEmail(cmd, event, attachments); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Email sent")
+	},
+}
+
+// sendEmail sends the event with the title rendered as subject template.
+func sendEmail(cmd *cobra.Command, event *notify.Event, attachments []email.Attachment) error {
+	host, _ := cmd.Flags().GetString("host")
+	port, _ := cmd.Flags().GetInt("port")
+	security, _ := cmd.Flags().GetString("security")
+	auth, _ := cmd.Flags().GetString("auth")
+	user, _ := cmd.Flags().GetString("user")
+	pass, _ := cmd.Flags().GetString("pass")
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetStringSlice("to")
+	cc, _ := cmd.Flags().GetStringSlice("cc")
+	useMarkdown, _ := cmd.Flags().GetBool("markdown")
+
+	if !cmd.Flags().Changed("port") && email.Security(security) == email.SecurityTLS {
+		port = 465
+	}
+	if !cmd.Flags().Changed("auth") && user == "" {
+		auth = string(email.AuthNone)
+	}
+
+	subject, err := event.Render(event.Title)
+	if err != nil {
+		return fmt.Errorf("invalid subject template: %w", err)
+	}
+
+	message := &email.Message{
+		From:        from,
+		To:          to,
+		Cc:          cc,
+		Subject:     subject,
+		Text:        event.Message,
+		Attachments: attachments,
+	}
+	if useMarkdown {
+		if message.HTML, err = markdown.ToHTML(event.Message); err != nil {
+			return err
+		}
+	}
+
+	server := &email.Server{
+		Host:     host,
+		Port:     port,
+		Security: email.Security(security),
+		Auth:     email.AuthMethod(auth),
+		Username: user,
+		Password: pass,
+	}
+
+	return email.Send(server, message)
+}
+
+func ensureEmailConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("host") {
+		return errors.New("host must be provided")
+	}
+
+	if !cmd.Flags().Changed("from") {
+		return errors.New("from must be provided")
+	}
+
+	if !cmd.Flags().Changed("to") {
+		return errors.New("to must be provided")
+	}
+
+	if cmd.Flags().Changed("user") && !cmd.Flags().Changed("pass") {
+		return errors.New("password must be provided if username is provided")
+	}
+
+	security, _ := cmd.Flags().GetString("security")
+	switch email.Security(security) {
+	case email.SecurityStartTLS, email.SecurityTLS, email.SecurityNone:
+	default:
+		return errors.New("security must be starttls, tls or none")
+	}
+
+	auth, _ := cmd.Flags().GetString("auth")
+	switch email.AuthMethod(auth) {
+	case email.AuthPlain, email.AuthLogin, email.AuthNone:
+	default:
+		return errors.New("auth must be plain, login or none")
+	}
+
+	return nil
+}
+
+func ensureEmailCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	if !cmd.Flags().Changed("subject") {
+		return errors.New("subject must be provided")
+	}
+
+	return nil
+}
+
+func ensureEmailWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(emailCmd)
+	emailCmd.AddCommand(emailWrapCmd)
+
+	emailCmd.PersistentFlags().String("host", "", "SMTP server")
+	emailCmd.PersistentFlags().Int("port", 587, "SMTP port (default 465 with tls)")
+	emailCmd.PersistentFlags().String("security", string(email.SecurityStartTLS), "Connection security (starttls, tls, none)")
+	emailCmd.PersistentFlags().String("auth", string(email.AuthPlain), "Authentication mechanism if a user is set (plain, login, none)")
+	emailCmd.PersistentFlags().StringP("user", "u", "", "Username for the SMTP server")
+	emailCmd.PersistentFlags().StringP("pass", "p", "", "Password for the SMTP server")
+	emailCmd.PersistentFlags().String("from", "", "Sender address")
+	emailCmd.PersistentFlags().StringSlice("to", []string{}, "Recipient addresses")
+	emailCmd.PersistentFlags().StringSlice("cc", []string{}, "Carbon copy addresses")
+	emailCmd.PersistentFlags().StringP("subject", "T", "", "Subject, a template like \"{{ .Status }} on {{ .Host }}{{ with .Result }}: {{ .Command }}{{ end }}\"")
+	emailCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	emailCmd.PersistentFlags().Bool("markdown", false, "Send the message rendered from markdown as HTML alternative")
+
+	emailWrapCmd.Flags().Bool("fail", false, "Send an email only if the command fails")
+	emailWrapCmd.Flags().Bool("success", false, "Send an email only if the command succeeds")
+	emailWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	emailWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	emailWrapCmd.Flags().Bool("attach-output", false, "Attach the output as file instead of adding it to the message")
+}
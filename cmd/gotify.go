@@ -0,0 +1,172 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/gotify"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var gotifyCmd = &cobra.Command{
+	Use:   "gotify",
+	Short: "Send a push notification to a gotify instance",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureGotifyConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureGotifyCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendGotifyMessage(cmd, message); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Notification sent")
+	},
+}
+
+var gotifyWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send a push notification to a gotify instance",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureGotifyConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureGotifyWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		outputLimit, _ := cmd.Flags().GetInt("output-limit")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending notification")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending notification")
+			return
+		}
+
+		// gotify rejects empty messages
+		message = result.MessageWithin(message, onlyMessage, outputLimit)
+		if strings.TrimSpace(message) == "" {
+			message = result.Summary()
+		}
+
+		if err := sendGotifyMessage(cmd, message); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Notification sent")
+	},
+}
+
+func sendGotifyMessage(cmd *cobra.Command, message string) error {
+	instance, _ := cmd.Flags().GetString("instance")
+	token, _ := cmd.Flags().GetString("token")
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetInt("priority")
+	markdown, _ := cmd.Flags().GetBool("markdown")
+	url, _ := cmd.Flags().GetString("url")
+
+	return gotify.SendMessage(gotify.NewMessage(title, message, priority, markdown, url), withScheme(instance), token)
+}
+
+func ensureGotifyConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("instance") {
+		return errors.New("instance must be provided")
+	}
+
+	if !cmd.Flags().Changed("token") {
+		return errors.New("application token must be provided")
+	}
+
+	return nil
+}
+
+func ensureGotifyCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureGotifyWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(gotifyCmd)
+	gotifyCmd.AddCommand(gotifyWrapCmd)
+
+	gotifyCmd.PersistentFlags().StringP("instance", "i", "", "gotify instance")
+	gotifyCmd.PersistentFlags().String("token", "", "Application token for the gotify instance")
+	gotifyCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	gotifyCmd.PersistentFlags().IntP("priority", "P", 5, "Message priority (0-10)")
+	gotifyCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	gotifyCmd.PersistentFlags().StringP("url", "U", "", "URL to open when the notification is clicked")
+	gotifyCmd.PersistentFlags().Bool("markdown", false, "Enable Markdown formatting in the notification body")
+
+	gotifyWrapCmd.Flags().Bool("fail", false, "Send a notification only if the command fails")
+	gotifyWrapCmd.Flags().Bool("success", false, "Send a notification only if the command succeeds")
+	gotifyWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	gotifyWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	gotifyWrapCmd.Flags().Int("output-limit", 4000, "Maximum size of the message in bytes, only the end of the output is kept")
+}
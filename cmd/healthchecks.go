@@ -0,0 +1,175 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/healthchecks"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var healthchecksCmd = &cobra.Command{
+	Use:   "healthchecks",
+	Short: "Send a ping to a healthchecks instance",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureHealthchecksConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureHealthchecksCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		signal, _ := cmd.Flags().GetString("signal")
+		rid, _ := cmd.Flags().GetString("rid")
+
+		s := healthchecks.Signal(signal)
+		if signal == "success" {
+			s = healthchecks.SignalSuccess
+		}
+		if cmd.Flags().Changed("exit-code") {
+			exitCode, _ := cmd.Flags().GetInt("exit-code")
+			s = healthchecks.ExitStatus(exitCode)
+		}
+
+		if err := healthchecks.SendPing(newHealthchecksCheck(cmd), s, message, rid); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Ping sent")
+	},
+}
+
+var healthchecksWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and report its start and exit code to a healthchecks instance",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureHealthchecksConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureHealthchecksWrapCmdConfigCorrect(args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		noStart, _ := cmd.Flags().GetBool("no-start")
+
+		check := newHealthchecksCheck(cmd)
+		rid := healthchecks.NewRunID()
+
+		if !noStart {
+			if err := healthchecks.SendPing(check, healthchecks.SignalStart, "", rid); err != nil {
+				slog.Warn("Could not send start ping", "error", err)
+			}
+		}
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if err := healthchecks.SendPing(check, healthchecks.ExitStatus(result.ExitCode), result.Message(message, onlyMessage), rid); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Ping sent")
+	},
+}
+
+func newHealthchecksCheck(cmd *cobra.Command) *healthchecks.Check {
+	instance, _ := cmd.Flags().GetString("instance")
+	uuid, _ := cmd.Flags().GetString("uuid")
+	pingKey, _ := cmd.Flags().GetString("ping-key")
+	slug, _ := cmd.Flags().GetString("slug")
+	create, _ := cmd.Flags().GetBool("create")
+
+	return healthchecks.NewCheck(withScheme(instance), uuid, pingKey, slug, create)
+}
+
+func ensureHealthchecksConfigCorrect(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("uuid") && (cmd.Flags().Changed("ping-key") || cmd.Flags().Changed("slug")) {
+		return errors.New("only one of uuid or ping-key and slug can be provided")
+	} else if !cmd.Flags().Changed("uuid") && !(cmd.Flags().Changed("ping-key") && cmd.Flags().Changed("slug")) {
+		return errors.New("uuid or ping-key and slug must be provided")
+	}
+
+	return nil
+}
+
+func ensureHealthchecksCmdConfigCorrect(cmd *cobra.Command) error {
+	signal, _ := cmd.Flags().GetString("signal")
+	switch signal {
+	case "success", string(healthchecks.SignalStart), string(healthchecks.SignalFail), string(healthchecks.SignalLog):
+	default:
+		return fmt.Errorf("unknown signal %q, must be success, start, fail or log", signal)
+	}
+
+	if cmd.Flags().Changed("signal") && cmd.Flags().Changed("exit-code") {
+		return errors.New("only one of signal or exit-code can be provided")
+	}
+
+	return nil
+}
+
+func ensureHealthchecksWrapCmdConfigCorrect(args []string) error {
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(healthchecksCmd)
+	healthchecksCmd.AddCommand(healthchecksWrapCmd)
+
+	healthchecksCmd.PersistentFlags().StringP("instance", "i", "hc-ping.com", "Ping endpoint of the healthchecks instance")
+	healthchecksCmd.PersistentFlags().String("uuid", "", "UUID of the check")
+	healthchecksCmd.PersistentFlags().String("ping-key", "", "Ping key of the project, used with slug")
+	healthchecksCmd.PersistentFlags().String("slug", "", "Slug of the check, used with ping-key")
+	healthchecksCmd.PersistentFlags().Bool("create", false, "Create the check if the slug does not exist")
+	healthchecksCmd.PersistentFlags().StringP("message", "m", "", "Message sent as ping body")
+	healthchecksCmd.Flags().StringP("signal", "s", "success", "Signal to send (success, start, fail, log)")
+	healthchecksCmd.Flags().IntP("exit-code", "e", 0, "Report an exit code instead of a signal")
+	healthchecksCmd.Flags().String("rid", "", "Run ID to relate start and end pings of overlapping runs")
+
+	healthchecksWrapCmd.Flags().StringP("message", "m", "", "Message before stdout/stderr")
+	healthchecksWrapCmd.Flags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	healthchecksWrapCmd.Flags().Bool("no-start", false, "Don't send a start ping before running the command")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/state"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+// addJobStateFlags adds the flags of wrap commands that notify depending on
+// earlier runs of the job.
+func addJobStateFlags(c *cobra.Command) {
+	c.Flags().String("job-id", "", "ID of the job for its state between runs (default is a hash of host and command)")
+	c.Flags().Bool("on-change", false, "Notify only when the job starts failing or recovers")
+	c.Flags().Int("after-failures", 0, "Notify only after this many failures in a row")
+	c.Flags().Duration("renotify-every", 0, "Repeat the notification of a still failing job after this long, e.g. 6h")
+}
+
+func jobStatePolicy(cmd *cobra.Command) state.Policy {
+	onChange, _ := cmd.Flags().GetBool("on-change")
+	afterFailures, _ := cmd.Flags().GetInt("after-failures")
+	renotifyEvery, _ := cmd.Flags().GetDuration("renotify-every")
+
+	return state.Policy{OnChange: onChange, AfterFailures: afterFailures, RenotifyEvery: renotifyEvery}
+}
+
+func ensureJobStateConfigCorrect(cmd *cobra.Command) error {
+	p := jobStatePolicy(cmd)
+	if p.AfterFailures < 0 {
+		return errors.New("after-failures must not be negative")
+	} else if p.RenotifyEvery < 0 {
+		return errors.New("renotify-every must not be negative")
+	}
+
+	return nil
+}
+
+// recordJobState records the result in the state of the job and decides
+// whether it notifies. Without a policy every run notifies and no state is
+// kept. If the state can't be read or written, the run notifies.
+func recordJobState(cmd *cobra.Command, result *wrap.Result) state.Outcome {
+	p := jobStatePolicy(cmd)
+	if !p.Enabled() {
+		return state.Outcome{Notify: true}
+	}
+
+	id, _ := cmd.Flags().GetString("job-id")
+	if id == "" {
+		id = result.Fingerprint()
+	}
+
+	job, outcome, err := state.RecordRun(id, result.Failed(), time.Now(), p)
+	if err != nil {
+		fmt.Println("Failed to record job state:", err)
+		return state.Outcome{Notify: true}
+	}
+	slog.Debug("Recorded job state", "job", id, "failures", job.ConsecutiveFailures, "notify", outcome.Notify, "recovered", outcome.Recovered)
+
+	return outcome
+}
+
+// jobStateSummary describes failures in a row and recoveries, empty for
+// single failures and successes.
+func jobStateSummary(o state.Outcome) string {
+	since := o.FirstFailure.Format(time.RFC3339)
+	if o.Recovered && o.Failures == 1 {
+		return fmt.Sprintf("Recovered after failing at %s", since)
+	} else if o.Recovered {
+		return fmt.Sprintf("Recovered after %d failures in a row since %s", o.Failures, since)
+	} else if o.Failures > 1 {
+		return fmt.Sprintf("Failed %d times in a row since %s", o.Failures, since)
+	}
+
+	return ""
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/journal"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/rwxd/notify-me/services/syslog"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var journalCmd = &cobra.Command{
+	Use:   "journal",
+	Short: "Write a notification with structured fields to the systemd journal",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureJournalCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendJournalEvent(cmd, newJournalEvent(cmd, message)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Entry written")
+	},
+}
+
+var journalWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and write the result with structured fields to the systemd journal",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureJournalWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		outputLimit, _ := cmd.Flags().GetInt("output-limit")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not writing entry")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not writing entry")
+			return
+		}
+
+		event := newJournalEvent(cmd, result.MessageWithin(message, onlyMessage, outputLimit)).WithResult(result)
+		if err := sendJournalEvent(cmd, event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Entry written")
+	},
+}
+
+func newJournalEvent(cmd *cobra.Command, message string) *notify.Event {
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetString("priority")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+
+	return notify.NewEvent(title, message, ntfy.Priority(priority), tags, "")
+}
+
+// sendJournalEvent writes the event fields as NOTIFY_ME_* fields, e.g.
+// NOTIFY_ME_EXIT_CODE and NOTIFY_ME_COMMAND.
+func sendJournalEvent(cmd *cobra.Command, event *notify.Event) error {
+	socket, _ := cmd.Flags().GetString("socket")
+	identifier, _ := cmd.Flags().GetString("identifier")
+
+	fields := map[string]string{}
+	for name, value := range event.StringFields() {
+		// the message and the output are MESSAGE, the host is _HOSTNAME
+		if name == "message" || name == "output" || name == "host" {
+			continue
+		}
+		fields["NOTIFY_ME_"+strings.ToUpper(name)] = value
+	}
+
+	text := event.Message
+	if event.Title != "" {
+		text = event.Title + ": " + text
+	}
+
+	return journal.Send(socket, &journal.Entry{
+		Message:    text,
+		Priority:   int(syslog.SeverityFromPriority(event.Priority)),
+		Identifier: identifier,
+		Fields:     fields,
+	})
+}
+
+func ensureJournalCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureJournalWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(journalCmd)
+	journalCmd.AddCommand(journalWrapCmd)
+
+	journalCmd.PersistentFlags().String("socket", journal.Socket, "Socket of journald")
+	journalCmd.PersistentFlags().String("identifier", "notify-me", "Syslog identifier of the entry")
+	journalCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	journalCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	journalCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority (min, low, default, high, max)")
+	journalCmd.PersistentFlags().StringSlice("tags", []string{}, "Tags for the message")
+
+	journalWrapCmd.Flags().Bool("fail", false, "Write an entry only if the command fails")
+	journalWrapCmd.Flags().Bool("success", false, "Write an entry only if the command succeeds")
+	journalWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	journalWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	journalWrapCmd.Flags().Int("output-limit", 64*1024, "Maximum size of the message in bytes, only the end of the output is kept")
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/state"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+// addLockFlags adds the flags of wrap commands that must not run twice at
+// the same time.
+func addLockFlags(c *cobra.Command) {
+	c.Flags().String("lock", "", "Name of a lock, only one run holding it runs at a time")
+	c.Flags().String("lock-policy", "skip", "What happens if the lock is held (skip, wait, kill the previous run)")
+	c.Flags().Duration("lock-timeout", time.Hour, "How long to wait for the lock, with kill after stopping the previous run")
+}
+
+func ensureLockConfigCorrect(cmd *cobra.Command) error {
+	policy, _ := cmd.Flags().GetString("lock-policy")
+	switch policy {
+	case "skip", "wait", "kill":
+	default:
+		return errors.New("lock policy must be skip, wait or kill")
+	}
+
+	return nil
+}
+
+// lockError is why a run didn't take the lock, skipped runs are no failure.
+type lockError struct {
+	message string
+	skipped bool
+}
+
+func (e *lockError) Error() string {
+	return e.message
+}
+
+// lockKillGrace is how long the kill policy waits for the previous run to
+// exit after terminating it, before killing it.
+const lockKillGrace = 10 * time.Second
+
+// jobLock is the held lock of a run, without --lock it holds nothing.
+type jobLock struct {
+	lock *state.Lock
+	// Note describes what happened to get the lock, for the notification.
+	Note string
+}
+
+// acquireJobLock takes the lock of --lock by the lock policy. A *lockError
+// describes what happened if the lock wasn't taken.
+func acquireJobLock(cmd *cobra.Command, command string) (*jobLock, error) {
+	name, _ := cmd.Flags().GetString("lock")
+	policy, _ := cmd.Flags().GetString("lock-policy")
+	timeout, _ := cmd.Flags().GetDuration("lock-timeout")
+	if name == "" {
+		return &jobLock{}, nil
+	}
+
+	lock, err := state.NewLock(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := lock.TryLock(); err != nil {
+		return nil, err
+	} else if ok {
+		return &jobLock{lock: lock}, nil
+	}
+
+	pid := lock.PID()
+	slog.Debug("Lock is held", "lock", name, "pid", pid, "policy", policy)
+
+	jl := &jobLock{lock: lock}
+	switch policy {
+	case "skip":
+		return nil, &lockError{message: fmt.Sprintf("Skipped %s, lock %s is held by the previous run (pid %d)", command, name, pid), skipped: true}
+	case "kill":
+		if pid == 0 {
+			break
+		}
+		if err := wrap.StopGroup(pid, lockKillGrace); err != nil {
+			slog.Debug("Failed to stop the previous run", "pid", pid, "error", err)
+		} else {
+			jl.Note = fmt.Sprintf("Stopped the previous run (pid %d) holding lock %s", pid, name)
+		}
+	}
+
+	waited := time.Now()
+	if ok, err := lock.LockWithin(timeout); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, &lockError{message: fmt.Sprintf("Gave up %s after waiting %s, lock %s is still held by the previous run (pid %d)", command, timeout, name, pid)}
+	}
+
+	if policy == "wait" {
+		jl.Note = fmt.Sprintf("Waited %s for the previous run holding lock %s", time.Since(waited).Round(time.Second), name)
+	}
+
+	return jl, nil
+}
+
+// Run runs the command, with a held lock in its own process group whose
+// leader is recorded in the lock, so a later run can stop all of it.
+func (jl *jobLock) Run(program string, args ...string) *wrap.Result {
+	if jl.lock == nil {
+		return wrap.Run(program, args...)
+	}
+
+	return wrap.RunStarted(jl.started, program, args...)
+}
+
+func (jl *jobLock) started(p *os.Process) {
+	if err := jl.lock.SetPID(p.Pid); err != nil {
+		slog.Debug("Failed to record the pid in the lock", "error", err)
+	}
+}
+
+func (jl *jobLock) Release() {
+	if jl.lock == nil {
+		return
+	}
+
+	jl.lock.Unlock()
+}
@@ -0,0 +1,255 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/markdown"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/matrix"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Send a message to a matrix room",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureMatrixConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureMatrixCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendMatrixMessage(cmd, message, ""); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+var matrixWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send a message to a matrix room",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureMatrixConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureMatrixWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending message")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending message")
+			return
+		}
+
+		output := ""
+		if !onlyMessage {
+			output = result.Output
+		}
+		if result.Err != nil {
+			message = result.Message(message, true)
+		}
+
+		if err := sendMatrixMessage(cmd, message, output); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+// sendMatrixMessage sends the message with the optional output as code block,
+// rendering markdown to the HTML formatted body if enabled.
+func sendMatrixMessage(cmd *cobra.Command, message, output string) error {
+	homeserver, _ := cmd.Flags().GetString("homeserver")
+	token, _ := cmd.Flags().GetString("token")
+	room, _ := cmd.Flags().GetString("room")
+	title, _ := cmd.Flags().GetString("title")
+	useMarkdown, _ := cmd.Flags().GetBool("markdown")
+	notice, _ := cmd.Flags().GetBool("notice")
+	txnID, _ := cmd.Flags().GetString("txn-id")
+	outputLimit, _ := cmd.Flags().GetInt("output-limit")
+
+	homeserver = withScheme(homeserver)
+
+	var body, formatted []string
+	if title != "" {
+		body = append(body, title)
+		formatted = append(formatted, "<strong>"+html.EscapeString(title)+"</strong>")
+	}
+	if message != "" {
+		body = append(body, message)
+		if useMarkdown {
+			rendered, err := markdown.ToHTML(message)
+			if err != nil {
+				return err
+			}
+			formatted = append(formatted, rendered)
+		} else {
+			formatted = append(formatted, strings.ReplaceAll(html.EscapeString(message), "\n", "<br>"))
+		}
+	}
+	if output != "" && outputLimit > 0 {
+		output = fitMatrixOutput(strings.Join(body, "\n"), strings.Join(formatted, "\n"), output, outputLimit)
+	}
+	if output != "" {
+		body = append(body, output)
+		formatted = append(formatted, "<pre><code>"+html.EscapeString(output)+"</code></pre>")
+	}
+
+	formattedBody := ""
+	if useMarkdown || output != "" || title != "" {
+		formattedBody = strings.Join(formatted, "\n")
+	}
+
+	roomID, err := matrix.ResolveRoom(homeserver, token, room)
+	if err != nil {
+		return err
+	}
+
+	if txnID == "" {
+		txnID = matrix.NewTransactionID()
+	}
+
+	return matrix.SendMessage(matrix.NewMessage(strings.Join(body, "\n"), formattedBody, notice), homeserver, token, roomID, txnID)
+}
+
+// fitMatrixOutput returns the end of the output that fits in limit bytes
+// together with the body and formatted body before it. The output is in both
+// of them, the size is measured JSON encoded like in the event.
+func fitMatrixOutput(body, formatted, output string, limit int) string {
+	size := func(out string) int {
+		b, _ := json.Marshal(body + "\n" + out)
+		f, _ := json.Marshal(formatted + "\n<pre><code>" + html.EscapeString(out) + "</code></pre>")
+		return len(b) + len(f)
+	}
+
+	for n := len(output); n > 0; {
+		out := wrap.Tail(output, n)
+		current := size(out)
+		if current <= limit {
+			return out
+		}
+		// escaping makes the size grow faster than the output
+		next := n * limit / current
+		if next >= n {
+			next = n - 1
+		}
+		n = next
+	}
+
+	slog.Debug("Title and message leave no room for the output")
+	return ""
+}
+
+func ensureMatrixConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("homeserver") {
+		return errors.New("homeserver must be provided")
+	}
+
+	if !cmd.Flags().Changed("token") {
+		return errors.New("access token must be provided")
+	}
+
+	if !cmd.Flags().Changed("room") {
+		return errors.New("room must be provided")
+	}
+
+	return nil
+}
+
+func ensureMatrixCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureMatrixWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(matrixCmd)
+	matrixCmd.AddCommand(matrixWrapCmd)
+
+	matrixCmd.PersistentFlags().StringP("homeserver", "s", "", "Matrix homeserver")
+	matrixCmd.PersistentFlags().String("token", "", "Access token of the sending user")
+	matrixCmd.PersistentFlags().StringP("room", "r", "", "Room ID or alias to send the message to")
+	matrixCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	matrixCmd.PersistentFlags().StringP("title", "T", "", "Message title, sent in bold before the message")
+	matrixCmd.PersistentFlags().Bool("markdown", false, "Render the message as markdown")
+	matrixCmd.PersistentFlags().Bool("notice", false, "Send as m.notice instead of m.text, for bots")
+	matrixCmd.PersistentFlags().String("txn-id", "", "Transaction ID, retries with the same ID are only delivered once")
+
+	matrixWrapCmd.Flags().Bool("fail", false, "Send a message only if the command fails")
+	matrixWrapCmd.Flags().Bool("success", false, "Send a message only if the command succeeds")
+	matrixWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	matrixWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	// events are limited to 64 KiB, with room for the rest of the event
+	matrixWrapCmd.Flags().Int("output-limit", 60000, "Maximum size of the message and its formatted copy in bytes, only the end of the output is kept")
+}
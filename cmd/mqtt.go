@@ -0,0 +1,220 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/mqtt"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var mqttCmd = &cobra.Command{
+	Use:   "mqtt",
+	Short: "Publish a notification as JSON event to an MQTT broker",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureMqttConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureMqttCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := publishMqttEvent(cmd, newMqttEvent(cmd, message)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Event published")
+	},
+}
+
+var mqttWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and publish the result as JSON event to an MQTT broker",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureMqttConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureMqttWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		outputLimit, _ := cmd.Flags().GetInt("output-limit")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not publishing event")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not publishing event")
+			return
+		}
+
+		event := newMqttEvent(cmd, result.MessageWithin(message, onlyMessage, outputLimit)).WithResult(result)
+		if err := publishMqttEvent(cmd, event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Event published")
+	},
+}
+
+func newMqttEvent(cmd *cobra.Command, message string) *notify.Event {
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetString("priority")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+
+	return notify.NewEvent(title, message, ntfy.Priority(priority), tags, "")
+}
+
+// publishMqttEvent publishes the event and, for wrapped commands with a status
+// topic, the retained last status.
+func publishMqttEvent(cmd *cobra.Command, event *notify.Event) error {
+	broker, _ := cmd.Flags().GetString("broker")
+	user, _ := cmd.Flags().GetString("user")
+	pass, _ := cmd.Flags().GetString("pass")
+	clientID, _ := cmd.Flags().GetString("client-id")
+	topic, _ := cmd.Flags().GetString("topic")
+	qos, _ := cmd.Flags().GetInt("qos")
+	retain, _ := cmd.Flags().GetBool("retain")
+	statusTopic, _ := cmd.Flags().GetString("status-topic")
+
+	fields := event.Fields()
+	// the message already carries the output
+	delete(fields, "output")
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	messages := []*mqtt.Message{{Topic: topic, Payload: payload, QoS: byte(qos), Retain: retain}}
+
+	if statusTopic != "" && event.Result != nil {
+		status, err := json.Marshal(map[string]any{
+			"state":            event.Status(),
+			"exit_code":        event.Result.ExitCode,
+			"duration_seconds": event.Result.Duration.Seconds(),
+			"command":          event.Result.Command(),
+			"host":             event.Host,
+			"time":             event.Time.Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		messages = append(messages, &mqtt.Message{Topic: statusTopic, Payload: status, QoS: byte(qos), Retain: true})
+	}
+
+	if clientID == "" {
+		clientID = fmt.Sprintf("notify-me-%d", os.Getpid())
+	}
+
+	return mqtt.Publish(mqtt.NewBroker(broker, user, pass, clientID), messages...)
+}
+
+func ensureMqttConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("broker") {
+		return errors.New("broker must be provided")
+	}
+
+	if cmd.Flags().Changed("user") && !cmd.Flags().Changed("pass") {
+		return errors.New("password must be provided if username is provided")
+	}
+
+	if qos, _ := cmd.Flags().GetInt("qos"); qos < 0 || qos > 2 {
+		return errors.New("qos must be 0, 1 or 2")
+	}
+
+	return nil
+}
+
+func ensureMqttCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureMqttWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(mqttCmd)
+	mqttCmd.AddCommand(mqttWrapCmd)
+
+	mqttCmd.PersistentFlags().StringP("broker", "b", "", "Broker URL (tcp://, ssl://, ws://, wss://)")
+	mqttCmd.PersistentFlags().StringP("user", "u", "", "Username for the broker")
+	mqttCmd.PersistentFlags().StringP("pass", "p", "", "Password for the broker")
+	mqttCmd.PersistentFlags().String("client-id", "", "Client ID (default notify-me-<pid>)")
+	mqttCmd.PersistentFlags().StringP("topic", "t", "notify-me/events", "Topic to publish the event to")
+	mqttCmd.PersistentFlags().Int("qos", 0, "Quality of service (0, 1, 2)")
+	mqttCmd.PersistentFlags().Bool("retain", false, "Retain the event on the broker")
+	mqttCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	mqttCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	mqttCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority (min, low, default, high, max)")
+	mqttCmd.PersistentFlags().StringSlice("tags", []string{}, "Tags for the message")
+
+	mqttWrapCmd.Flags().Bool("fail", false, "Publish an event only if the command fails")
+	mqttWrapCmd.Flags().Bool("success", false, "Publish an event only if the command succeeds")
+	mqttWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	mqttWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	mqttWrapCmd.Flags().Int("output-limit", 64*1024, "Maximum size of the message in bytes, only the end of the output is kept")
+	mqttWrapCmd.Flags().String("status-topic", "", "Topic for a retained last status message of the job, e.g. for Home Assistant sensors")
+}
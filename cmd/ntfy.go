@@ -22,16 +22,21 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
+	"time"
+	// timezones of the quiet hours without zoneinfo on the host
+	_ "time/tzdata"
 
+	"github.com/rwxd/notify-me/internal/ratelimit"
+	"github.com/rwxd/notify-me/internal/timerange"
 	"github.com/rwxd/notify-me/services/ntfy"
 	"github.com/sagikazarmark/slog-shim"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var ntfyCmd = &cobra.Command{
@@ -41,12 +46,14 @@ var ntfyCmd = &cobra.Command{
 		setupLogging()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		out := statusOutput(cmd)
+		cmd.SetOut(out)
 		if err := ensureNtfyConfigCorrect(cmd); err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(out, err)
 			cmd.Help()
 			os.Exit(1)
 		} else if err := ensureNtfyCmdConfigCorrect(cmd); err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(out, err)
 			cmd.Help()
 			os.Exit(1)
 		}
@@ -54,13 +61,17 @@ var ntfyCmd = &cobra.Command{
 		instance, _ := cmd.Flags().GetString("instance")
 		tags, _ := cmd.Flags().GetStringSlice("tags")
 
-		if !strings.Contains(instance, "http://") || !strings.Contains(instance, "https://") {
-			instance = "https://" + instance
+		instance = withScheme(instance)
+
+		message, err := messageFromFlagOrStdin(cmd, true)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			os.Exit(1)
 		}
 
 		notification := ntfy.NewNotification(cmd.Flags().Lookup("topic").Value.String(),
 			cmd.Flags().Lookup("title").Value.String(),
-			cmd.Flags().Lookup("message").Value.String(),
+			message,
 			ntfy.Priority(cmd.Flags().Lookup("priority").Value.String()),
 			tags,
 			cmd.Flags().Lookup("url").Value.String(),
@@ -69,6 +80,10 @@ var ntfyCmd = &cobra.Command{
 			cmd.Flags().Lookup("icon").Value.String(),
 			cmd.Flags().Lookup("markdown").Changed,
 		)
+		if !applyNtfyQuietHours(cmd, notification) || !applyNtfyLimits(cmd, instance, notification) {
+			return
+		}
+
 		if err := ntfy.SendNotification(
 			notification,
 			instance,
@@ -76,11 +91,11 @@ var ntfyCmd = &cobra.Command{
 			cmd.Flags().Lookup("pass").Value.String(),
 			cmd.Flags().Lookup("token").Value.String(),
 		); err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(out, err)
 			os.Exit(1)
 		}
 
-		fmt.Println("Notification sent")
+		fmt.Fprintln(out, "Notification sent")
 	},
 }
 
@@ -103,13 +118,11 @@ var ntfyWrapCmd = &cobra.Command{
 
 		instance, _ := cmd.Flags().GetString("instance")
 		tags, _ := cmd.Flags().GetStringSlice("tags")
-		onlyFailure, _ := cmd.Flags().GetBool("error")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
 		onlySuccess, _ := cmd.Flags().GetBool("success")
 		onlyMessage, _ := cmd.Flags().GetBool("only-message")
 
-		if !strings.Contains(instance, "http://") || !strings.Contains(instance, "https://") {
-			instance = "https://" + instance
-		}
+		instance = withScheme(instance)
 
 		notification := ntfy.NewNotification(cmd.Flags().Lookup("topic").Value.String(),
 			cmd.Flags().Lookup("title").Value.String(),
@@ -123,70 +136,175 @@ var ntfyWrapCmd = &cobra.Command{
 			cmd.Flags().Lookup("markdown").Changed,
 		)
 
-		program := args[0]
-		programArgs := args[1:]
-		command := exec.Command(program, programArgs...)
+		jl, err := acquireJobLock(cmd, strings.Join(args, " "))
+		var lockErr *lockError
+		if errors.As(err, &lockErr) {
+			fmt.Println(lockErr)
+			notification.Message = lockErr.Error()
+			if applyNtfyQuietHours(cmd, notification) && applyNtfyLimits(cmd, instance, notification) {
+				sendNtfyNotification(cmd, instance, notification)
+			}
+			if !lockErr.skipped {
+				os.Exit(1)
+			}
+			return
+		} else if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		result := jl.Run(args[0], args[1:]...)
+		jl.Release()
+		outcome := recordJobState(cmd, result)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending notification")
+			return
+		} else if !result.Failed() && onlyFailure && !outcome.Recovered {
+			slog.Debug("Only sending on failure, not sending notification")
+			return
+		} else if !outcome.Notify {
+			slog.Debug("Not sending notification for this run of the job")
+			return
+		}
+
+		notification.Message = result.Message(notification.Message, onlyMessage)
+		if !applyNtfyQuietHours(cmd, notification) || !applyNtfyLimits(cmd, instance, notification, jl.Note, jobStateSummary(outcome)) {
+			return
+		}
+
+		sendNtfyNotification(cmd, instance, notification)
+	},
+}
+
+// sendNtfyNotification sends the notification and exits if that fails.
+func sendNtfyNotification(cmd *cobra.Command, instance string, notification *ntfy.Notification) {
+	if err := ntfy.SendNotification(
+		notification,
+		instance,
+		cmd.Flags().Lookup("user").Value.String(),
+		cmd.Flags().Lookup("pass").Value.String(),
+		cmd.Flags().Lookup("token").Value.String(),
+	); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Notification sent")
+}
+
+// applyNtfyQuietHours changes notifications below the threshold priority
+// during the quiet hours by the quiet action and reports whether the
+// notification should still be sent.
+func applyNtfyQuietHours(cmd *cobra.Command, notification *ntfy.Notification) bool {
+	window := viper.GetString("ntfy.quiet_hours")
+	if window == "" {
+		return true
+	}
 
-		var output bytes.Buffer
-		command.Stdout = &output
-		command.Stderr = &output
+	r, _ := timerange.Parse(window)
+	location, _ := time.LoadLocation(viper.GetString("ntfy.quiet_timezone"))
+	now := time.Now().In(location)
+	if !r.Contains(now) || notification.Priority.Level() >= ntfy.Priority(viper.GetString("ntfy.quiet_below")).Level() {
+		return true
+	}
 
-		slog.Debug("Running command", "program", program, "args", programArgs)
-		err := command.Run()
+	switch viper.GetString("ntfy.quiet_action") {
+	case "suppress":
+		fmt.Fprintln(statusOutput(cmd), "Quiet hours, notification not sent")
+		return false
+	case "delay":
+		if notification.Delay == "" {
+			notification.Delay = strconv.FormatInt(r.NextEnd(now).Unix(), 10)
+			slog.Debug("Quiet hours, delaying notification", "until", r.NextEnd(now))
+		}
+	default:
+		if notification.Priority.Level() > ntfy.PriorityLow.Level() {
+			slog.Debug("Quiet hours, downgrading notification", "priority", notification.Priority)
+			notification.Priority = ntfy.PriorityLow
+		}
+	}
 
-		if onlyMessage {
-		} else if notification.Message != "" {
-			notification.Message += "\n" + output.String()
+	return true
+}
+
+// applyNtfyLimits applies the rate limit of the topic and the dedup window
+// and reports whether the notification should still be sent. Notes about
+// held back notifications and the given notes are added to the message after
+// the fingerprint was taken, so they don't make repeated messages differ.
+func applyNtfyLimits(cmd *cobra.Command, instance string, notification *ntfy.Notification, notes ...string) bool {
+	limits, _ := ntfyLimits()
+	if limits.Rate != 0 || limits.DedupWindow != 0 {
+		fingerprint := ratelimit.Fingerprint(notification.Topic, notification.Title, notification.Message)
+		d, err := ratelimit.Check(instance+"/"+notification.Topic, fingerprint, limits, time.Now())
+		if err != nil {
+			fmt.Fprintln(statusOutput(cmd), "Failed to check the rate limit:", err)
+		} else if !d.Allow {
+			fmt.Fprintln(statusOutput(cmd), "Notification not sent,", d.Reason)
+			return false
 		} else {
-			notification.Message = output.String()
+			if viper.GetString("ntfy.dedup_mode") == "suppress" {
+				d.Repeated = 0
+			}
+			notes = append([]string{d.Summary()}, notes...)
 		}
+	}
 
+	for i := len(notes) - 1; i >= 0; i-- {
+		if notes[i] != "" {
+			notification.Message = notes[i] + "\n" + notification.Message
+		}
+	}
+
+	return true
+}
+
+func ntfyLimits() (ratelimit.Limits, error) {
+	var limits ratelimit.Limits
+	if rate := viper.GetString("ntfy.rate_limit"); rate != "" {
+		n, per, err := ratelimit.ParseRate(rate)
 		if err != nil {
-			slog.Debug("Command failed", "error", err)
+			return limits, err
+		}
+		limits.Rate, limits.Per = n, per
+	}
 
-			if notification.Message == "" {
-				notification.Message = err.Error()
-			} else {
-				notification.Message += "\n" + err.Error()
-			}
+	limits.DedupWindow = viper.GetDuration("ntfy.dedup_window")
 
-			if onlySuccess {
-				slog.Debug("Only sending on success, not sending notification")
-				return
-			}
+	return limits, nil
+}
 
-			if err := ntfy.SendNotification(
-				notification,
-				instance,
-				cmd.Flags().Lookup("user").Value.String(),
-				cmd.Flags().Lookup("pass").Value.String(),
-				cmd.Flags().Lookup("token").Value.String(),
-			); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			fmt.Println("Notification sent")
-		} else {
-			slog.Debug("Command succeeded")
+func ensureNtfyLimitsCorrect() error {
+	if _, err := ntfyLimits(); err != nil {
+		return err
+	}
 
-			if onlyFailure {
-				slog.Debug("Only sending on failure, not sending notification")
-				return
-			}
+	switch viper.GetString("ntfy.dedup_mode") {
+	case "collapse", "suppress":
+	default:
+		return errors.New("dedup mode must be collapse or suppress")
+	}
 
-			if err := ntfy.SendNotification(
-				notification,
-				instance,
-				cmd.Flags().Lookup("user").Value.String(),
-				cmd.Flags().Lookup("pass").Value.String(),
-				cmd.Flags().Lookup("token").Value.String(),
-			); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			fmt.Println("Notification sent")
+	return nil
+}
+
+func ensureNtfyQuietHoursCorrect() error {
+	if window := viper.GetString("ntfy.quiet_hours"); window != "" {
+		if _, err := timerange.Parse(window); err != nil {
+			return err
 		}
-	},
+	}
+
+	if _, err := time.LoadLocation(viper.GetString("ntfy.quiet_timezone")); err != nil {
+		return fmt.Errorf("invalid quiet hours timezone: %w", err)
+	}
+
+	switch viper.GetString("ntfy.quiet_action") {
+	case "downgrade", "suppress", "delay":
+	default:
+		return errors.New("quiet action must be downgrade, suppress or delay")
+	}
+
+	return nil
 }
 
 func ensureNtfyConfigCorrect(cmd *cobra.Command) error {
@@ -202,27 +320,35 @@ func ensureNtfyConfigCorrect(cmd *cobra.Command) error {
 		return errors.New("topic must be provided")
 	}
 
-	return nil
+	if err := ensureNtfyQuietHoursCorrect(); err != nil {
+		return err
+	}
+
+	return ensureNtfyLimitsCorrect()
 }
 
 func ensureNtfyCmdConfigCorrect(cmd *cobra.Command) error {
-	if !cmd.Flags().Changed("message") {
-		return errors.New("message must be provided")
+	if !cmd.Flags().Changed("message") && !stdinPiped() {
+		return errors.New("message must be provided, with - or a pipe it is read from stdin")
 	}
 
 	return nil
 }
 
 func ensureNtfyWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
-	if cmd.Flags().Changed("erorr") && cmd.Flags().Changed("success") {
-		return errors.New("only one of error or success can be provided")
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
 	}
 
 	if len(args) == 0 {
 		return errors.New("command must be provided")
 	}
 
-	return nil
+	if err := ensureJobStateConfigCorrect(cmd); err != nil {
+		return err
+	}
+
+	return ensureLockConfigCorrect(cmd)
 }
 
 func init() {
@@ -234,7 +360,7 @@ func init() {
 	ntfyCmd.PersistentFlags().StringP("pass", "p", "", "Password for the ntfy instance")
 	ntfyCmd.PersistentFlags().String("token", "", "Access token for the ntfy instance")
 	ntfyCmd.PersistentFlags().StringP("topic", "t", "", "Topic to send the message to")
-	ntfyCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	ntfyCmd.PersistentFlags().StringP("message", "m", "", "Message, - reads it from stdin (default is stdin if it is piped)")
 	ntfyCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority (min, low, default, high, max")
 	ntfyCmd.PersistentFlags().StringSlice("tags", []string{}, "Tags for the message")
 	ntfyCmd.PersistentFlags().StringP("title", "T", "", "Message title")
@@ -243,9 +369,27 @@ func init() {
 	ntfyCmd.PersistentFlags().String("delay", "", "Timestamp or duration for delayed delivery")
 	ntfyCmd.PersistentFlags().String("icon", "", "URL to use as notification icon")
 	ntfyCmd.PersistentFlags().Bool("markdown", false, "Enable Markdown formatting in the notification body")
+	ntfyCmd.PersistentFlags().String("quiet-hours", "", "Time of day without notifications below the threshold, e.g. 22:00-07:00")
+	ntfyCmd.PersistentFlags().String("quiet-timezone", "Local", "Timezone of the quiet hours, e.g. Europe/Berlin")
+	ntfyCmd.PersistentFlags().String("quiet-below", string(ntfy.PriorityHigh), "Priorities below this one are quiet during the quiet hours")
+	ntfyCmd.PersistentFlags().String("quiet-action", "downgrade", "What happens with quiet notifications (downgrade to low, suppress, delay until the end)")
+	ntfyCmd.PersistentFlags().String("rate-limit", "", "Maximum notifications to the topic from this host, e.g. 10/m")
+	ntfyCmd.PersistentFlags().Duration("dedup-window", 0, "Suppress identical notifications within this duration, e.g. 10m")
+	ntfyCmd.PersistentFlags().String("dedup-mode", "collapse", "Mention suppressed duplicates in the next identical notification (collapse) or not (suppress)")
+	viper.BindPFlag("ntfy.rate_limit", ntfyCmd.PersistentFlags().Lookup("rate-limit"))
+	viper.BindPFlag("ntfy.dedup_window", ntfyCmd.PersistentFlags().Lookup("dedup-window"))
+	viper.BindPFlag("ntfy.dedup_mode", ntfyCmd.PersistentFlags().Lookup("dedup-mode"))
+	viper.BindPFlag("ntfy.quiet_hours", ntfyCmd.PersistentFlags().Lookup("quiet-hours"))
+	viper.BindPFlag("ntfy.quiet_timezone", ntfyCmd.PersistentFlags().Lookup("quiet-timezone"))
+	viper.BindPFlag("ntfy.quiet_below", ntfyCmd.PersistentFlags().Lookup("quiet-below"))
+	viper.BindPFlag("ntfy.quiet_action", ntfyCmd.PersistentFlags().Lookup("quiet-action"))
+
+	addStdinFlags(ntfyCmd, 4096)
 
 	ntfyWrapCmd.Flags().Bool("fail", false, "Send a notification only if the command fails")
 	ntfyWrapCmd.Flags().Bool("success", false, "Send a notification only if the command succeeds")
 	ntfyWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
 	ntfyWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	addJobStateFlags(ntfyWrapCmd)
+	addLockFlags(ntfyWrapCmd)
 }
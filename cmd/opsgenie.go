@@ -0,0 +1,223 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/rwxd/notify-me/services/opsgenie"
+	"github.com/spf13/cobra"
+)
+
+var opsgenieCmd = &cobra.Command{
+	Use:   "opsgenie",
+	Short: "Create or close an opsgenie alert",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureOpsgenieConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureOpsgenieCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		action, _ := cmd.Flags().GetString("action")
+		message, _ := cmd.Flags().GetString("message")
+		description, _ := cmd.Flags().GetString("description")
+		alias, _ := cmd.Flags().GetString("alias")
+
+		var err error
+		if action == "close" {
+			err = closeOpsgenieAlert(cmd, alias, message)
+		} else {
+			err = createOpsgenieAlert(cmd, newOpsgenieAlert(cmd, alias, message, description))
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var opsgenieWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command, create an alert if it fails and close it when it succeeds again",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureOpsgenieConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureOpsgenieWrapCmdConfigCorrect(args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		alias, _ := cmd.Flags().GetString("alias")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if alias == "" {
+			alias = "notify-me-" + result.Fingerprint()
+		}
+
+		var err error
+		if result.Failed() {
+			if message == "" {
+				message = fmt.Sprintf("%s failed on %s", result.Command(), result.Host)
+			}
+			alert := newOpsgenieAlert(cmd, alias, message, result.MessageWithin("", false, opsgenie.DescriptionLimit))
+			alert.Details = map[string]string{
+				"command":   result.Command(),
+				"exit_code": strconv.Itoa(result.ExitCode),
+				"duration":  result.Duration.Round(time.Millisecond).String(),
+			}
+			err = createOpsgenieAlert(cmd, alert)
+		} else {
+			err = closeOpsgenieAlert(cmd, alias, result.Command()+" succeeded")
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func newOpsgenieAlert(cmd *cobra.Command, alias, message, description string) *opsgenie.Alert {
+	priority, _ := cmd.Flags().GetString("priority")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	entity, _ := cmd.Flags().GetString("entity")
+
+	return &opsgenie.Alert{
+		Message:     message,
+		Alias:       alias,
+		Description: description,
+		Tags:        tags,
+		Entity:      entity,
+		Source:      opsgenieSource(cmd),
+		Priority:    opsgeniePriority(ntfy.Priority(priority)),
+	}
+}
+
+func createOpsgenieAlert(cmd *cobra.Command, alert *opsgenie.Alert) error {
+	if err := newOpsgenieClient(cmd).CreateAlert(alert); err != nil {
+		return err
+	}
+
+	fmt.Println("Alert created, alias:", alert.Alias)
+	return nil
+}
+
+func closeOpsgenieAlert(cmd *cobra.Command, alias, note string) error {
+	if err := newOpsgenieClient(cmd).CloseAlert(alias, opsgenieSource(cmd), note); err != nil {
+		return err
+	}
+
+	fmt.Println("Alert closed, alias:", alias)
+	return nil
+}
+
+func newOpsgenieClient(cmd *cobra.Command) *opsgenie.Client {
+	apiUrl, _ := cmd.Flags().GetString("api-url")
+	apiKey, _ := cmd.Flags().GetString("api-key")
+
+	return opsgenie.NewClient(withScheme(apiUrl), apiKey)
+}
+
+func opsgenieSource(cmd *cobra.Command) string {
+	source, _ := cmd.Flags().GetString("source")
+	if source == "" {
+		source, _ = os.Hostname()
+	}
+
+	return source
+}
+
+// opsgeniePriority maps the ntfy priorities, max is P1 and min is P5.
+func opsgeniePriority(priority ntfy.Priority) opsgenie.Priority {
+	return opsgenie.Priority("P" + strconv.Itoa(6-priority.Level()))
+}
+
+func ensureOpsgenieConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("api-key") {
+		return errors.New("api key must be provided")
+	}
+
+	return nil
+}
+
+func ensureOpsgenieCmdConfigCorrect(cmd *cobra.Command) error {
+	action, _ := cmd.Flags().GetString("action")
+	switch action {
+	case "create":
+		if !cmd.Flags().Changed("message") {
+			return errors.New("message must be provided to create an alert")
+		}
+	case "close":
+		if !cmd.Flags().Changed("alias") {
+			return errors.New("alias must be provided to close an alert")
+		}
+	default:
+		return errors.New("action must be create or close")
+	}
+
+	return nil
+}
+
+func ensureOpsgenieWrapCmdConfigCorrect(args []string) error {
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(opsgenieCmd)
+	opsgenieCmd.AddCommand(opsgenieWrapCmd)
+
+	opsgenieCmd.PersistentFlags().String("api-url", "https://api.opsgenie.com", "Base URL of the Alert API, https://api.eu.opsgenie.com for the EU")
+	opsgenieCmd.PersistentFlags().String("api-key", "", "API key of the integration")
+	opsgenieCmd.PersistentFlags().String("alias", "", "Alias identifying the alert, for wrap derived from host and command")
+	opsgenieCmd.PersistentFlags().StringP("message", "m", "", "Message of the alert")
+	opsgenieCmd.PersistentFlags().StringP("priority", "P", "", "Alert Priority (min, low, default, high, max)")
+	opsgenieCmd.PersistentFlags().StringSlice("tags", []string{}, "Tags for the alert")
+	opsgenieCmd.PersistentFlags().String("entity", "", "Entity the alert is related to")
+	opsgenieCmd.PersistentFlags().String("source", "", "Source of the alert (default hostname)")
+	opsgenieCmd.Flags().String("action", "create", "Action (create, close)")
+	opsgenieCmd.Flags().String("description", "", "Description of the alert")
+}
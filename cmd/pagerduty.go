@@ -0,0 +1,219 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/pagerduty"
+	"github.com/spf13/cobra"
+)
+
+// pagerdutyOutputLimit is how much of the output is sent in the custom details.
+const pagerdutyOutputLimit = 16 * 1024
+
+var pagerdutyCmd = &cobra.Command{
+	Use:   "pagerduty",
+	Short: "Trigger, acknowledge or resolve a pagerduty incident",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensurePagerdutyConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensurePagerdutyCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		action, _ := cmd.Flags().GetString("action")
+		message, _ := cmd.Flags().GetString("message")
+		dedupKey, _ := cmd.Flags().GetString("dedup-key")
+
+		event := newPagerdutyEvent(cmd, pagerduty.Action(action), dedupKey)
+		if event.EventAction == pagerduty.ActionTrigger {
+			event.Payload = newPagerdutyPayload(cmd, message)
+		}
+
+		if err := sendPagerdutyEvent(cmd, event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var pagerdutyWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command, trigger an incident if it fails and resolve it when it succeeds again",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensurePagerdutyConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensurePagerdutyWrapCmdConfigCorrect(args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		dedupKey, _ := cmd.Flags().GetString("dedup-key")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if dedupKey == "" {
+			dedupKey = "notify-me-" + result.Fingerprint()
+		}
+
+		event := newPagerdutyEvent(cmd, pagerduty.ActionResolve, dedupKey)
+		if result.Failed() {
+			if message == "" {
+				message = fmt.Sprintf("%s failed on %s: %s", result.Command(), result.Host, result.Err)
+			}
+			event.EventAction = pagerduty.ActionTrigger
+			event.Payload = newPagerdutyPayload(cmd, message)
+			event.Payload.CustomDetails = map[string]any{
+				"command":   result.Command(),
+				"exit_code": result.ExitCode,
+				"duration":  result.Duration.Round(time.Millisecond).String(),
+				"output":    wrap.Tail(result.Output, pagerdutyOutputLimit),
+			}
+		}
+
+		if err := sendPagerdutyEvent(cmd, event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func newPagerdutyEvent(cmd *cobra.Command, action pagerduty.Action, dedupKey string) *pagerduty.Event {
+	routingKey, _ := cmd.Flags().GetString("routing-key")
+
+	return &pagerduty.Event{
+		RoutingKey:  routingKey,
+		EventAction: action,
+		DedupKey:    dedupKey,
+	}
+}
+
+func newPagerdutyPayload(cmd *cobra.Command, summary string) *pagerduty.Payload {
+	severity, _ := cmd.Flags().GetString("severity")
+	source, _ := cmd.Flags().GetString("source")
+	component, _ := cmd.Flags().GetString("component")
+	group, _ := cmd.Flags().GetString("group")
+	class, _ := cmd.Flags().GetString("class")
+
+	if source == "" {
+		source, _ = os.Hostname()
+	}
+
+	return &pagerduty.Payload{
+		Summary:   summary,
+		Source:    source,
+		Severity:  pagerduty.Severity(severity),
+		Component: component,
+		Group:     group,
+		Class:     class,
+	}
+}
+
+func sendPagerdutyEvent(cmd *cobra.Command, event *pagerduty.Event) error {
+	apiUrl, _ := cmd.Flags().GetString("api-url")
+
+	dedupKey, err := pagerduty.SendEvent(withScheme(apiUrl), event)
+	if err != nil {
+		return err
+	} else if dedupKey == "" {
+		dedupKey = event.DedupKey
+	}
+
+	fmt.Printf("Sent %s event to pagerduty, dedup key: %s\n", event.EventAction, dedupKey)
+	return nil
+}
+
+func ensurePagerdutyConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("routing-key") {
+		return errors.New("routing key must be provided")
+	}
+
+	severity, _ := cmd.Flags().GetString("severity")
+	switch pagerduty.Severity(severity) {
+	case pagerduty.SeverityCritical, pagerduty.SeverityError, pagerduty.SeverityWarning, pagerduty.SeverityInfo:
+	default:
+		return errors.New("severity must be critical, error, warning or info")
+	}
+
+	return nil
+}
+
+func ensurePagerdutyCmdConfigCorrect(cmd *cobra.Command) error {
+	action, _ := cmd.Flags().GetString("action")
+	switch pagerduty.Action(action) {
+	case pagerduty.ActionTrigger:
+		if !cmd.Flags().Changed("message") {
+			return errors.New("message must be provided to trigger an incident")
+		}
+	case pagerduty.ActionAcknowledge, pagerduty.ActionResolve:
+		if !cmd.Flags().Changed("dedup-key") {
+			return fmt.Errorf("dedup key must be provided to %s an incident", action)
+		}
+	default:
+		return errors.New("action must be trigger, acknowledge or resolve")
+	}
+
+	return nil
+}
+
+func ensurePagerdutyWrapCmdConfigCorrect(args []string) error {
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pagerdutyCmd)
+	pagerdutyCmd.AddCommand(pagerdutyWrapCmd)
+
+	pagerdutyCmd.PersistentFlags().String("api-url", "https://events.pagerduty.com", "Base URL of the Events API")
+	pagerdutyCmd.PersistentFlags().String("routing-key", "", "Integration key of the service")
+	pagerdutyCmd.PersistentFlags().String("dedup-key", "", "Key identifying the incident, for wrap derived from host and command")
+	pagerdutyCmd.PersistentFlags().StringP("message", "m", "", "Summary of the incident")
+	pagerdutyCmd.PersistentFlags().String("severity", string(pagerduty.SeverityError), "Severity (critical, error, warning, info)")
+	pagerdutyCmd.PersistentFlags().String("source", "", "Source of the incident (default hostname)")
+	pagerdutyCmd.PersistentFlags().String("component", "", "Component responsible for the incident")
+	pagerdutyCmd.PersistentFlags().String("group", "", "Logical grouping of components")
+	pagerdutyCmd.PersistentFlags().String("class", "", "Class or type of the incident")
+	pagerdutyCmd.Flags().String("action", string(pagerduty.ActionTrigger), "Event action (trigger, acknowledge, resolve)")
+}
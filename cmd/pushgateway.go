@@ -0,0 +1,153 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/pushgateway"
+	"github.com/spf13/cobra"
+)
+
+var pushgatewayCmd = &cobra.Command{
+	Use:   "pushgateway",
+	Short: "Push job metrics to a Prometheus Pushgateway",
+}
+
+var pushgatewayWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and push its run metrics to a Prometheus Pushgateway",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensurePushgatewayConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		url, _ := cmd.Flags().GetString("url")
+		user, _ := cmd.Flags().GetString("user")
+		pass, _ := cmd.Flags().GetString("pass")
+		job, _ := cmd.Flags().GetString("job")
+		rusage, _ := cmd.Flags().GetBool("rusage")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		client := pushgateway.NewClient(withScheme(url), user, pass)
+		if err := client.Push(job, pushgatewayGrouping(cmd), pushgatewayGauges(cmd, result, rusage)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Metrics pushed")
+	},
+}
+
+// pushgatewayGauges builds the run metrics. last_success_timestamp is left out
+// for failed runs, so the pushgateway keeps the time of the last success.
+func pushgatewayGauges(cmd *cobra.Command, result *wrap.Result, rusage bool) []pushgateway.Gauge {
+	prefix, _ := cmd.Flags().GetString("prefix")
+
+	finished := float64(result.Started.Add(result.Duration).UnixMilli()) / 1000
+	gauges := []pushgateway.Gauge{
+		{Name: prefix + "last_run_timestamp", Help: "Time the last run finished in seconds since epoch", Value: finished},
+		{Name: prefix + "duration_seconds", Help: "Duration of the last run in seconds", Value: result.Duration.Seconds()},
+		{Name: prefix + "exit_code", Help: "Exit code of the last run, -1 if it could not be started", Value: float64(result.ExitCode)},
+	}
+	if !result.Failed() {
+		gauges = append(gauges, pushgateway.Gauge{Name: prefix + "last_success_timestamp", Help: "Time the last successful run finished in seconds since epoch", Value: finished})
+	}
+
+	if rusage && result.State != nil {
+		gauges = append(gauges,
+			pushgateway.Gauge{Name: prefix + "user_cpu_seconds", Help: "User CPU time of the last run in seconds", Value: result.State.UserTime().Seconds()},
+			pushgateway.Gauge{Name: prefix + "system_cpu_seconds", Help: "System CPU time of the last run in seconds", Value: result.State.SystemTime().Seconds()},
+		)
+		if maxRSS, ok := result.MaxRSS(); ok {
+			gauges = append(gauges, pushgateway.Gauge{Name: prefix + "max_rss_bytes", Help: "Peak resident memory of the last run in bytes", Value: float64(maxRSS)})
+		}
+	}
+
+	return gauges
+}
+
+func pushgatewayGrouping(cmd *cobra.Command) []pushgateway.Label {
+	instance, _ := cmd.Flags().GetString("instance")
+	grouping, _ := cmd.Flags().GetStringArray("grouping")
+
+	var labels []pushgateway.Label
+	if instance != "" {
+		labels = append(labels, pushgateway.Label{Name: "instance", Value: instance})
+	}
+	for _, g := range grouping {
+		name, value, _ := strings.Cut(g, "=")
+		labels = append(labels, pushgateway.Label{Name: name, Value: value})
+	}
+
+	return labels
+}
+
+func ensurePushgatewayConfigCorrect(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("url") {
+		return errors.New("url must be provided")
+	}
+
+	if !cmd.Flags().Changed("job") {
+		return errors.New("job must be provided")
+	}
+
+	grouping, _ := cmd.Flags().GetStringArray("grouping")
+	for _, g := range grouping {
+		if name, _, found := strings.Cut(g, "="); !found || name == "" || name == "job" {
+			return fmt.Errorf("grouping %q must be in the format label=value and not job", g)
+		}
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pushgatewayCmd)
+	pushgatewayCmd.AddCommand(pushgatewayWrapCmd)
+
+	hostname, _ := os.Hostname()
+
+	pushgatewayCmd.PersistentFlags().String("url", "", "URL of the Pushgateway")
+	pushgatewayCmd.PersistentFlags().StringP("user", "u", "", "Username for basic auth")
+	pushgatewayCmd.PersistentFlags().StringP("pass", "p", "", "Password for basic auth")
+	pushgatewayCmd.PersistentFlags().String("job", "", "Job label of the metrics")
+	pushgatewayCmd.PersistentFlags().String("instance", hostname, "Instance label of the grouping key, empty to leave it out")
+	pushgatewayCmd.PersistentFlags().StringArray("grouping", []string{}, "Additional grouping key label in the format label=value, can be repeated")
+	pushgatewayCmd.PersistentFlags().String("prefix", "", "Prefix for the metric names")
+
+	pushgatewayWrapCmd.Flags().Bool("rusage", false, "Also push CPU time and peak memory of the command")
+}
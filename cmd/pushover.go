@@ -0,0 +1,238 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/rwxd/notify-me/services/pushover"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var pushoverCmd = &cobra.Command{
+	Use:   "pushover",
+	Short: "Send a push notification with pushover",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensurePushoverConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensurePushoverCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendPushoverMessage(cmd, message); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var pushoverWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send a push notification with pushover",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensurePushoverConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensurePushoverWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending notification")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending notification")
+			return
+		}
+
+		// pushover rejects blank messages
+		message = result.MessageWithin(message, onlyMessage, pushover.MessageLimit)
+		if strings.TrimSpace(message) == "" {
+			message = result.Summary()
+		}
+
+		if err := sendPushoverMessage(cmd, message); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func sendPushoverMessage(cmd *cobra.Command, message string) error {
+	apiUrl, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	user, _ := cmd.Flags().GetString("user")
+	devices, _ := cmd.Flags().GetStringSlice("device")
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetString("priority")
+	url, _ := cmd.Flags().GetString("url")
+	urlTitle, _ := cmd.Flags().GetString("url-title")
+	retry, _ := cmd.Flags().GetDuration("retry")
+	expire, _ := cmd.Flags().GetDuration("expire")
+	useHtml, _ := cmd.Flags().GetBool("html")
+	waitAck, _ := cmd.Flags().GetBool("wait-ack")
+
+	client := pushover.NewClient(withScheme(apiUrl), token)
+	receipt, err := client.SendMessage(&pushover.Message{
+		User:     user,
+		Message:  message,
+		Title:    title,
+		Devices:  devices,
+		Priority: pushoverPriority(priority),
+		Url:      url,
+		UrlTitle: urlTitle,
+		Retry:    retry,
+		Expire:   expire,
+		Html:     useHtml,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Notification sent")
+	if receipt == "" || !waitAck {
+		return nil
+	}
+
+	fmt.Println("Waiting for acknowledgement, receipt:", receipt)
+	r, err := client.WaitForReceipt(receipt, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	if r.Acknowledged != 1 {
+		return errors.New("emergency notification expired without acknowledgement")
+	}
+
+	fmt.Printf("Acknowledged by %s at %s\n", r.AcknowledgedBy, time.Unix(r.AcknowledgedAt, 0).Format(time.RFC3339))
+	return nil
+}
+
+// pushoverPriority maps the ntfy priority names, or a number from -2 to 2, to
+// the pushover priority.
+func pushoverPriority(priority string) int {
+	if p, err := strconv.Atoi(priority); err == nil && p >= pushover.PriorityLowest && p <= pushover.PriorityEmergency {
+		return p
+	}
+
+	return ntfy.Priority(priority).Level() - 3
+}
+
+func ensurePushoverConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("token") {
+		return errors.New("application token must be provided")
+	}
+
+	if !cmd.Flags().Changed("user") {
+		return errors.New("user key must be provided")
+	}
+
+	priority, _ := cmd.Flags().GetString("priority")
+	if pushoverPriority(priority) == pushover.PriorityEmergency {
+		retry, _ := cmd.Flags().GetDuration("retry")
+		expire, _ := cmd.Flags().GetDuration("expire")
+		if retry < 30*time.Second {
+			return errors.New("retry must be at least 30s for emergency priority")
+		} else if expire > 3*time.Hour {
+			return errors.New("expire must be at most 3h for emergency priority")
+		}
+	}
+
+	if cmd.Flags().Changed("wait-ack") && pushoverPriority(priority) != pushover.PriorityEmergency {
+		return errors.New("wait-ack only works with emergency priority")
+	}
+
+	return nil
+}
+
+func ensurePushoverCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensurePushoverWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pushoverCmd)
+	pushoverCmd.AddCommand(pushoverWrapCmd)
+
+	pushoverCmd.PersistentFlags().String("api-url", "https://api.pushover.net", "Base URL of the pushover API")
+	pushoverCmd.PersistentFlags().String("token", "", "Application token")
+	pushoverCmd.PersistentFlags().String("user", "", "User or group key")
+	pushoverCmd.PersistentFlags().StringSlice("device", []string{}, "Devices to send to instead of all devices of the user")
+	pushoverCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	pushoverCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	pushoverCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority (min, low, default, high, max or -2 to 2), max is an emergency")
+	pushoverCmd.PersistentFlags().StringP("url", "U", "", "URL shown with the notification")
+	pushoverCmd.PersistentFlags().String("url-title", "", "Title of the URL")
+	pushoverCmd.PersistentFlags().Duration("retry", time.Minute, "How often an emergency notification is repeated")
+	pushoverCmd.PersistentFlags().Duration("expire", time.Hour, "How long an emergency notification is repeated")
+	pushoverCmd.PersistentFlags().Bool("wait-ack", false, "Wait until an emergency notification is acknowledged or expired")
+	pushoverCmd.PersistentFlags().Bool("html", false, "Enable HTML formatting in the message")
+
+	pushoverWrapCmd.Flags().Bool("fail", false, "Send a notification only if the command fails")
+	pushoverWrapCmd.Flags().Bool("success", false, "Send a notification only if the command succeeds")
+	pushoverWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	pushoverWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -63,6 +64,15 @@ func setupLogging() {
 	slog.SetDefault(logger)
 }
 
+// withScheme prefixes an instance without a scheme with https://.
+func withScheme(instance string) string {
+	if !strings.HasPrefix(instance, "http://") && !strings.HasPrefix(instance, "https://") {
+		return "https://" + instance
+	}
+
+	return instance
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -0,0 +1,245 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/schedule"
+	"github.com/rwxd/notify-me/internal/state"
+	"github.com/rwxd/notify-me/internal/target"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run the jobs of the config file by their cron schedules and send notifications",
+	Long: `Run the jobs of the config file by their cron schedules and send their
+results to the targets of the job, or by the "rules" and "notify" list of
+the config file like "notify-me send". Runs until it is stopped.
+
+  timezone: Europe/Berlin
+  notify:
+    - ntfys://token@ntfy.example/jobs
+  jobs:
+    - name: backup
+      schedule: "0 30 2 * * *"   # seconds are optional, @daily and @every 1h work too
+      command: ["/usr/local/bin/backup.sh", "--full"]
+      jitter: 5m                 # start up to 5m later
+      catch_up: true             # run at start if a run was missed
+      only: failure              # failure or success
+      after_failures: 2          # also on_change and renotify_every
+    - name: cleanup
+      schedule: "@hourly"
+      shell: "find /tmp -mtime +7 -delete"
+      notify:
+        - kumas://status.example/pushToken`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		jobs, err := loadScheduledJobs(cmd)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var wg sync.WaitGroup
+		for _, j := range jobs {
+			job, err := state.Load(j.Name)
+			if err != nil {
+				fmt.Printf("Failed to load state of job %s: %s\n", j.Name, err)
+				job = &state.Job{ID: j.Name}
+			}
+
+			fmt.Printf("Scheduled job %s, next run at %s\n", j.Name, j.schedule.Next(time.Now()).Format(time.RFC3339))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				j.schedule.Loop(ctx, job.LastRun, j.CatchUp, func(time.Time) { j.run() })
+			}()
+		}
+
+		<-ctx.Done()
+		fmt.Println("Stopping, waiting for running jobs")
+		wg.Wait()
+	},
+}
+
+// scheduledJob is a job of the "jobs" list of the config file.
+type scheduledJob struct {
+	Name          string        `mapstructure:"name"`
+	Schedule      string        `mapstructure:"schedule"`
+	Timezone      string        `mapstructure:"timezone"`
+	Jitter        time.Duration `mapstructure:"jitter"`
+	CatchUp       bool          `mapstructure:"catch_up"`
+	Command       []string      `mapstructure:"command"`
+	Shell         string        `mapstructure:"shell"`
+	Notify        []string      `mapstructure:"notify"`
+	Title         string        `mapstructure:"title"`
+	Message       string        `mapstructure:"message"`
+	Priority      string        `mapstructure:"priority"`
+	Tags          []string      `mapstructure:"tags"`
+	Only          string        `mapstructure:"only"`
+	OnChange      bool          `mapstructure:"on_change"`
+	AfterFailures int           `mapstructure:"after_failures"`
+	RenotifyEvery time.Duration `mapstructure:"renotify_every"`
+	OutputLimit   int           `mapstructure:"output_limit"`
+
+	schedule *schedule.Schedule
+	targets  []*target.Target
+	router   *sendRouter
+}
+
+// loadScheduledJobs reads and checks the jobs, so config errors show at
+// start and not at the first run.
+func loadScheduledJobs(cmd *cobra.Command) ([]*scheduledJob, error) {
+	var jobs []*scheduledJob
+	if err := viper.UnmarshalKey("jobs", &jobs); err != nil {
+		return nil, fmt.Errorf("invalid jobs in config file: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("no jobs in the config file, see notify-me schedule --help")
+	}
+
+	var router *sendRouter
+	names := map[string]bool{}
+	for i, j := range jobs {
+		if j.Name == "" {
+			return nil, fmt.Errorf("job #%d: name must be provided", i+1)
+		} else if names[j.Name] {
+			return nil, fmt.Errorf("job %s: name must be unique", j.Name)
+		}
+		names[j.Name] = true
+
+		if (len(j.Command) == 0) == (j.Shell == "") {
+			return nil, fmt.Errorf("job %s: one of command or shell must be provided", j.Name)
+		}
+		if j.Only != "" && j.Only != "failure" && j.Only != "success" {
+			return nil, fmt.Errorf("job %s: only must be failure or success", j.Name)
+		}
+		if !ntfy.Priority(j.Priority).Valid() {
+			return nil, fmt.Errorf("job %s: priority must be min, low, default, high, max or 1 to 5", j.Name)
+		}
+		if j.OutputLimit == 0 {
+			j.OutputLimit = 4000
+		}
+
+		timezone := j.Timezone
+		if timezone == "" {
+			timezone = viper.GetString("timezone")
+		}
+		s, err := schedule.Parse(j.Schedule, timezone, j.Jitter)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", j.Name, err)
+		}
+		j.schedule = s
+
+		for _, u := range j.Notify {
+			t, err := target.Parse(u)
+			if err != nil {
+				return nil, fmt.Errorf("job %s: %w", j.Name, err)
+			}
+			j.targets = append(j.targets, t)
+		}
+
+		if len(j.targets) == 0 && router == nil {
+			if router, err = newSendRouter(cmd); err != nil {
+				return nil, fmt.Errorf("job %s: %w", j.Name, err)
+			}
+		}
+		j.router = router
+	}
+
+	return jobs, nil
+}
+
+// run runs the job once, records its state and sends the notifications.
+func (j *scheduledJob) run() {
+	fmt.Printf("Running job %s\n", j.Name)
+
+	var result *wrap.Result
+	if j.Shell != "" {
+		result = wrap.Run("/bin/sh", "-c", j.Shell)
+	} else {
+		result = wrap.Run(j.Command[0], j.Command[1:]...)
+	}
+
+	policy := state.Policy{OnChange: j.OnChange, AfterFailures: j.AfterFailures, RenotifyEvery: j.RenotifyEvery}
+	_, outcome, err := state.RecordRun(j.Name, result.Failed(), time.Now(), policy)
+	if err != nil {
+		fmt.Printf("Failed to record state of job %s: %s\n", j.Name, err)
+		outcome = state.Outcome{Notify: true}
+	}
+
+	event := notify.NewEvent(j.Title, "", ntfy.Priority(j.Priority), j.Tags, "").WithResult(result)
+	fmt.Printf("Job %s finished with %s after %s, next run at %s\n", j.Name, event.Status(), result.Duration.Round(time.Millisecond), j.schedule.Next(time.Now()).Format(time.RFC3339))
+
+	if result.Failed() && j.Only == "success" {
+		return
+	} else if !result.Failed() && j.Only == "failure" && !outcome.Recovered {
+		return
+	} else if !outcome.Notify {
+		slog.Debug("Not sending notifications for this run of the job", "job", j.Name)
+		return
+	}
+
+	message := j.Message
+	if summary := jobStateSummary(outcome); summary != "" && message != "" {
+		message = summary + "\n" + message
+	} else if summary != "" {
+		message = summary
+	}
+	event.Message = result.MessageWithin(message, false, j.OutputLimit)
+	if event.Title == "" {
+		event.Title = fmt.Sprintf("%s: %s", j.Name, event.Status())
+	}
+
+	var routed []routedTarget
+	if len(j.targets) > 0 {
+		for _, t := range j.targets {
+			routed = append(routed, routedTarget{target: t, event: event})
+		}
+	} else {
+		routed = j.router.route(event)
+	}
+	sendToTargets(routed)
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+}
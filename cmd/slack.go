@@ -0,0 +1,206 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/slack"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var slackCmd = &cobra.Command{
+	Use:     "slack",
+	Aliases: []string{"mattermost", "rocketchat"},
+	Short:   "Send a message to a Slack compatible incoming webhook",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureSlackConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureSlackCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message := newSlackMessage(cmd)
+		message.Text, _ = cmd.Flags().GetString("message")
+		message.Color, _ = cmd.Flags().GetString("color")
+
+		if err := sendSlackMessage(cmd, message); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+var slackWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send the result to a Slack compatible incoming webhook",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureSlackConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureSlackWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		outputLimit, _ := cmd.Flags().GetInt("output-limit")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending message")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending message")
+			return
+		}
+
+		message := newSlackMessage(cmd)
+		message.Text, _ = cmd.Flags().GetString("message")
+		if message.Title == "" {
+			message.Title = result.Command()
+		}
+		message.Color = slack.ColorSuccess
+		if result.Failed() {
+			message.Color = slack.ColorFailure
+		}
+		if !onlyMessage {
+			message.Output = wrap.Tail(result.Output, outputLimit)
+		}
+		if result.ExitCode == -1 {
+			// the command could not be started, show why instead of an exit code
+			message.Text = result.Message(message.Text, true)
+		}
+		message.Fields = []slack.Field{
+			{Title: "Host", Value: result.Host, Short: true},
+			{Title: "Exit code", Value: strconv.Itoa(result.ExitCode), Short: true},
+			{Title: "Duration", Value: result.Duration.Round(time.Millisecond).String(), Short: true},
+		}
+
+		if err := sendSlackMessage(cmd, message); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+func newSlackMessage(cmd *cobra.Command) *slack.Message {
+	title, _ := cmd.Flags().GetString("title")
+	url, _ := cmd.Flags().GetString("url")
+	channel, _ := cmd.Flags().GetString("channel")
+	username, _ := cmd.Flags().GetString("username")
+	icon, _ := cmd.Flags().GetString("icon")
+
+	return &slack.Message{
+		Title:    title,
+		Url:      url,
+		Channel:  channel,
+		Username: username,
+		Icon:     icon,
+	}
+}
+
+func sendSlackMessage(cmd *cobra.Command, message *slack.Message) error {
+	webhook, _ := cmd.Flags().GetString("webhook")
+	format, _ := cmd.Flags().GetString("format")
+
+	return slack.SendMessage(message, webhook, slack.Format(format))
+}
+
+func ensureSlackConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("webhook") {
+		return errors.New("webhook must be provided")
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if slack.Format(format) != slack.FormatAttachments && slack.Format(format) != slack.FormatBlocks {
+		return fmt.Errorf("format must be %s or %s", slack.FormatAttachments, slack.FormatBlocks)
+	}
+
+	return nil
+}
+
+func ensureSlackCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureSlackWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(slackCmd)
+	slackCmd.AddCommand(slackWrapCmd)
+
+	slackCmd.PersistentFlags().StringP("webhook", "w", "", "Incoming webhook URL")
+	slackCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	slackCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	slackCmd.PersistentFlags().StringP("url", "U", "", "URL linked from the title")
+	slackCmd.PersistentFlags().String("channel", "", "Channel to post to instead of the webhook default")
+	slackCmd.PersistentFlags().String("username", "", "Username to post as")
+	slackCmd.PersistentFlags().String("icon", "", "Icon URL or :emoji: to post with")
+	slackCmd.PersistentFlags().String("format", string(slack.FormatAttachments), "Message format (attachments for Slack/Mattermost/Rocket.Chat, blocks for Slack Block Kit)")
+	slackCmd.Flags().String("color", "", "Color of the attachment bar (good, warning, danger or a hex color)")
+
+	slackWrapCmd.Flags().Bool("fail", false, "Send a message only if the command fails")
+	slackWrapCmd.Flags().Bool("success", false, "Send a message only if the command succeeds")
+	slackWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	slackWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	slackWrapCmd.Flags().Int("output-limit", 2500, "Maximum number of bytes of the output tail to send")
+}
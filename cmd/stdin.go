@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/spf13/cobra"
+)
+
+// addStdinFlags adds the flags of commands reading the message from stdin.
+func addStdinFlags(c *cobra.Command, outputLimit int) {
+	c.Flags().Bool("tee", false, "Copy stdin to stdout while reading the message from it")
+	c.Flags().Int("output-limit", outputLimit, "Maximum size of a message from stdin in bytes, only the end is kept")
+}
+
+// stdinPiped reports whether stdin is a pipe or a file, so a message can be
+// read from it. Terminals and /dev/null are not.
+func stdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// messageFromFlagOrStdin returns the message flag, or stdin if the message
+// is "-". With detect stdin is also read if the message is not given and
+// stdin is piped, only for commands that required a message before, so
+// existing calls in loops reading a file don't swallow its input. Like wrap,
+// only the end of a long input is kept.
+func messageFromFlagOrStdin(cmd *cobra.Command, detect bool) (string, error) {
+	message, _ := cmd.Flags().GetString("message")
+	tee, _ := cmd.Flags().GetBool("tee")
+	limit, _ := cmd.Flags().GetInt("output-limit")
+
+	if message != "-" && (cmd.Flags().Changed("message") || !detect || !stdinPiped()) {
+		return message, nil
+	}
+
+	var input io.Reader = os.Stdin
+	if tee {
+		input = io.TeeReader(os.Stdin, os.Stdout)
+	}
+
+	buf := &tailBuffer{max: limit}
+	if _, err := io.Copy(buf, input); err != nil {
+		return "", err
+	}
+
+	return wrap.Tail(string(buf.data), limit), nil
+}
+
+// statusOutput is where commands reading stdin print status and errors, stderr
+// with --tee so stdout only has the input.
+func statusOutput(cmd *cobra.Command) io.Writer {
+	if tee, _ := cmd.Flags().GetBool("tee"); tee {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
+
+// tailBuffer keeps more than the last max bytes written, so wrap.Tail can
+// tell that it cut the input.
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if b.max > 0 && len(b.data) > 2*b.max+4096 {
+		b.data = append(b.data[:0], b.data[len(b.data)-b.max-4096:]...)
+	}
+
+	return len(p), nil
+}
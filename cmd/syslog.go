@@ -0,0 +1,199 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/rwxd/notify-me/services/syslog"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var syslogCmd = &cobra.Command{
+	Use:   "syslog",
+	Short: "Write a notification as RFC 5424 message to syslog",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureSyslogConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureSyslogCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendSyslogEvent(cmd, newSyslogEvent(cmd, message)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message written")
+	},
+}
+
+var syslogWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and write the result as RFC 5424 message to syslog",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureSyslogConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureSyslogWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+		outputLimit, _ := cmd.Flags().GetInt("output-limit")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not writing message")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not writing message")
+			return
+		}
+
+		event := newSyslogEvent(cmd, result.MessageWithin(message, onlyMessage, outputLimit)).WithResult(result)
+		if err := sendSyslogEvent(cmd, event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message written")
+	},
+}
+
+func newSyslogEvent(cmd *cobra.Command, message string) *notify.Event {
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetString("priority")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+
+	return notify.NewEvent(title, message, ntfy.Priority(priority), tags, "")
+}
+
+func sendSyslogEvent(cmd *cobra.Command, event *notify.Event) error {
+	address, _ := cmd.Flags().GetString("address")
+	facilityName, _ := cmd.Flags().GetString("facility")
+	appName, _ := cmd.Flags().GetString("app-name")
+	insecure, _ := cmd.Flags().GetBool("tls-insecure")
+
+	facility, err := syslog.ParseFacility(facilityName)
+	if err != nil {
+		return err
+	}
+
+	// message, output and host are already part of the message or header
+	data := event.StringFields()
+	delete(data, "message")
+	delete(data, "output")
+	delete(data, "host")
+	delete(data, "time")
+
+	text := event.Message
+	if event.Title != "" {
+		text = event.Title + ": " + text
+	}
+
+	return syslog.Send(address, &syslog.Message{
+		Facility:       facility,
+		Severity:       syslog.SeverityFromPriority(event.Priority),
+		Time:           event.Time,
+		Hostname:       event.Host,
+		AppName:        appName,
+		MsgID:          event.Status(),
+		StructuredData: data,
+		Message:        text,
+	}, &tls.Config{InsecureSkipVerify: insecure})
+}
+
+func ensureSyslogConfigCorrect(cmd *cobra.Command) error {
+	facility, _ := cmd.Flags().GetString("facility")
+	if _, err := syslog.ParseFacility(facility); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func ensureSyslogCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureSyslogWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(syslogCmd)
+	syslogCmd.AddCommand(syslogWrapCmd)
+
+	syslogCmd.PersistentFlags().StringP("address", "a", "unix:///dev/log", "Syslog address (unix:///dev/log, udp://host:514, tcp://host:514, tls://host:6514)")
+	syslogCmd.PersistentFlags().String("facility", "user", "Syslog facility (user, daemon, cron, local0-local7)")
+	syslogCmd.PersistentFlags().String("app-name", "notify-me", "App name in the message header")
+	syslogCmd.PersistentFlags().Bool("tls-insecure", false, "Skip verification of the server certificate")
+	syslogCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	syslogCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	syslogCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority (min, low, default, high, max)")
+	syslogCmd.PersistentFlags().StringSlice("tags", []string{}, "Tags for the message")
+
+	syslogWrapCmd.Flags().Bool("fail", false, "Write a message only if the command fails")
+	syslogWrapCmd.Flags().Bool("success", false, "Write a message only if the command succeeds")
+	syslogWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	syslogWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	syslogWrapCmd.Flags().Int("output-limit", 8*1024, "Maximum size of the message in bytes, only the end of the output is kept")
+}
@@ -0,0 +1,247 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/rwxd/notify-me/services/telegram"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var telegramCmd = &cobra.Command{
+	Use:   "telegram",
+	Short: "Send a message with a telegram bot",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureTelegramConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureTelegramCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendTelegramMessage(cmd, message, "", ""); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+var telegramWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send a message with a telegram bot",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureTelegramConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureTelegramWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending message")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending message")
+			return
+		}
+
+		output := ""
+		if !onlyMessage {
+			output = result.Output
+		}
+		failure := ""
+		if result.Err != nil {
+			failure = result.Err.Error()
+		}
+
+		if err := sendTelegramMessage(cmd, message, failure, output); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Message sent")
+	},
+}
+
+// sendTelegramMessage sends the title, message, failure and output as one
+// message, or the output as document if the message would exceed the telegram
+// limit. Unlike the message, the failure is always escaped for the parse mode.
+func sendTelegramMessage(cmd *cobra.Command, message, failure, output string) error {
+	apiUrl, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	chat, _ := cmd.Flags().GetString("chat")
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetString("priority")
+	format, _ := cmd.Flags().GetString("format")
+
+	bot := telegram.NewBot(withScheme(apiUrl), token)
+	silent := ntfy.Priority(priority).Level() < 3
+
+	// text is escaped for the HTML parse mode, so title and output can be formatted
+	parseMode := telegram.ParseModeHTML
+	escape, escapeCode := telegram.EscapeHTML, telegram.EscapeHTML
+	bold := func(s string) string { return "<b>" + s + "</b>" }
+	pre := func(s string) string { return "<pre>" + s + "</pre>" }
+	switch format {
+	case "text":
+		message = telegram.EscapeHTML(message)
+	case "markdown":
+		parseMode = telegram.ParseModeMarkdownV2
+		escape, escapeCode = telegram.EscapeMarkdownV2, telegram.EscapeMarkdownV2Code
+		bold = func(s string) string { return "*" + s + "*" }
+		pre = func(s string) string { return "```\n" + s + "\n```" }
+	}
+
+	text := message
+	if failure != "" && text != "" {
+		text += "\n" + escape(failure)
+	} else if failure != "" {
+		text = escape(failure)
+	}
+	if title != "" {
+		text = bold(escape(title)) + "\n" + text
+	}
+
+	if output == "" || utf8.RuneCountInString(text+pre(escapeCode(output))) <= telegram.MessageLimit {
+		if output != "" {
+			text += "\n" + pre(escapeCode(output))
+		}
+		if utf8.RuneCountInString(text) > telegram.MessageLimit {
+			return fmt.Errorf("message is longer than %d characters", telegram.MessageLimit)
+		}
+
+		return bot.SendMessage(&telegram.Message{
+			ChatID:                chat,
+			Text:                  text,
+			ParseMode:             parseMode,
+			DisableNotification:   silent,
+			DisableWebPagePreview: true,
+		})
+	}
+
+	slog.Debug("Output too long for a message, sending as document")
+	if utf8.RuneCountInString(text) > telegram.CaptionLimit {
+		if err := bot.SendMessage(&telegram.Message{
+			ChatID:              chat,
+			Text:                text,
+			ParseMode:           parseMode,
+			DisableNotification: silent,
+		}); err != nil {
+			return err
+		}
+		text = ""
+	}
+
+	return bot.SendDocument(&telegram.Document{
+		ChatID:              chat,
+		FileName:            "output.txt",
+		Data:                []byte(output),
+		Caption:             text,
+		ParseMode:           parseMode,
+		DisableNotification: silent,
+	})
+}
+
+func ensureTelegramConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("token") {
+		return errors.New("bot token must be provided")
+	}
+
+	if !cmd.Flags().Changed("chat") {
+		return errors.New("chat must be provided")
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "markdown" && format != "html" {
+		return errors.New("format must be text, markdown or html")
+	}
+
+	return nil
+}
+
+func ensureTelegramCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("message") {
+		return errors.New("message must be provided")
+	}
+
+	return nil
+}
+
+func ensureTelegramWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(telegramCmd)
+	telegramCmd.AddCommand(telegramWrapCmd)
+
+	telegramCmd.PersistentFlags().String("api-url", "https://api.telegram.org", "Base URL of the Bot API")
+	telegramCmd.PersistentFlags().String("token", "", "Bot token")
+	telegramCmd.PersistentFlags().StringP("chat", "c", "", "Chat ID or @channelusername to send the message to")
+	telegramCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	telegramCmd.PersistentFlags().StringP("title", "T", "", "Message title, sent in bold before the message")
+	telegramCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority, min and low are sent silently (min, low, default, high, max)")
+	telegramCmd.PersistentFlags().String("format", "text", "Format of the message (text, markdown for MarkdownV2, html)")
+
+	telegramWrapCmd.Flags().Bool("fail", false, "Send a message only if the command fails")
+	telegramWrapCmd.Flags().Bool("success", false, "Send a message only if the command succeeds")
+	telegramWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	telegramWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+}
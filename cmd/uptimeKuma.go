@@ -22,12 +22,15 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/rwxd/notify-me/internal/wrap"
 	uptimekuma "github.com/rwxd/notify-me/services/uptimeKuma"
 	"github.com/sagikazarmark/slog-shim"
 	"github.com/spf13/cobra"
@@ -40,33 +43,50 @@ var uptimeKumaCmd = &cobra.Command{
 		setupLogging()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		out := statusOutput(cmd)
+		cmd.SetOut(out)
 		if err := ensureUptimeKumaConfigCorrect(cmd); err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(out, err)
 			cmd.Help()
 			os.Exit(1)
 		} else if err := ensureUptimeKumaDefaultCmdConfigCorrect(cmd); err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(out, err)
 			cmd.Help()
 			os.Exit(1)
 		}
 
 		instance, _ := cmd.Flags().GetString("instance")
 		token, _ := cmd.Flags().GetString("token")
-		message, _ := cmd.Flags().GetString("message")
-		ping, _ := cmd.Flags().GetString("ping")
 		down, _ := cmd.Flags().GetBool("down")
 		up, _ := cmd.Flags().GetBool("up")
 
-		if !strings.Contains(instance, "http://") || !strings.Contains(instance, "https://") {
-			instance = "https://" + instance
+		instance = withScheme(instance)
+
+		message, err := messageFromFlagOrStdin(cmd, false)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			os.Exit(1)
+		}
+
+		push := uptimekuma.NewPushRequest(up || !down, message)
+		if cmd.Flags().Changed("ping") {
+			ping, _ := cmd.Flags().GetFloat64("ping")
+			push.WithPing(ping)
+		} else if cmd.Flags().Changed("ping-command") {
+			ping, err := measureUptimeKumaPing(cmd, nil)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				os.Exit(1)
+			}
+			push.WithPing(ping)
 		}
 
-		if err := uptimekuma.SendMonitorStatus(instance, token, up || !down, message, ping); err != nil {
-			fmt.Println(err)
+		if err := uptimekuma.SendPush(instance, token, push); err != nil {
+			fmt.Fprintln(out, err)
 			os.Exit(1)
 		}
 
-		fmt.Println("Sent status to uptime-kuma")
+		fmt.Fprintln(out, "Sent status to uptime-kuma")
 	},
 }
 
@@ -91,79 +111,178 @@ var uptimeKumaWrapCmd = &cobra.Command{
 		token, _ := cmd.Flags().GetString("token")
 		message, _ := cmd.Flags().GetString("message")
 		onlyMessage, _ := cmd.Flags().GetBool("only-message")
-		onlyFailure, _ := cmd.Flags().GetBool("error")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
 		onlySuccess, _ := cmd.Flags().GetBool("success")
 		reverse, _ := cmd.Flags().GetBool("reverse")
 
-		if !strings.Contains(instance, "http://") || !strings.Contains(instance, "https://") {
-			instance = "https://" + instance
+		instance = withScheme(instance)
+
+		jl, err := acquireJobLock(cmd, strings.Join(args, " "))
+		var lockErr *lockError
+		if errors.As(err, &lockErr) {
+			fmt.Println(lockErr)
+			// a skipped run means the previous one is still healthy, only
+			// giving up on the lock is a failure
+			if err := uptimekuma.SendPush(instance, token, uptimekuma.NewPushRequest(lockErr.skipped, lockErr.Error())); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Println("Sent status to uptime-kuma")
+			if !lockErr.skipped {
+				os.Exit(1)
+			}
+			return
+		} else if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		result := jl.Run(args[0], args[1:]...)
+		jl.Release()
+		message = result.Message(message, onlyMessage)
+		if jl.Note != "" {
+			message = jl.Note + "\n" + message
+		}
+
+		statusUp := !result.Failed()
+		if reverse {
+			slog.Debug("Reverse is set, inverting status")
+			statusUp = !statusUp
+		}
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only success is set, not sending status")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only error is set, not sending status")
+			return
 		}
 
-		program := args[0]
-		programArgs := args[1:]
+		if !statusUp && uptimeKumaInMaintenance(cmd, instance) {
+			fmt.Println("Monitor is in maintenance, not sending down status")
+			return
+		}
 
-		command := exec.Command(program, programArgs...)
+		push := uptimekuma.NewPushRequest(statusUp, message)
+		if cmd.Flags().Changed("ping-from") || cmd.Flags().Changed("ping-command") {
+			ping, err := measureUptimeKumaPing(cmd, result)
+			if err != nil {
+				slog.Warn("Could not measure ping", "error", err)
+			} else {
+				push.WithPing(ping)
+			}
+		}
 
-		var output bytes.Buffer
-		command.Stdout = &output
-		command.Stderr = &output
+		if err := uptimekuma.SendPush(instance, token, push); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 
-		slog.Debug("Running command", "program", program, "args", programArgs)
-		err := command.Run()
+		fmt.Println("Sent status to uptime-kuma")
+	},
+}
 
-		if onlyMessage {
-		} else if message != "" {
-			message += "\n" + output.String()
-		} else {
-			message = output.String()
+var uptimeKumaStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print the monitor states of an uptime-kuma status page",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureUptimeKumaStatusCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
 		}
 
-		statusUp := true
+		instance, _ := cmd.Flags().GetString("instance")
+		slug, _ := cmd.Flags().GetString("slug")
+
+		page, err := uptimekuma.GetStatusPage(withScheme(instance), slug)
 		if err != nil {
-			slog.Debug("Command failed", "error", err)
+			fmt.Println(err)
+			os.Exit(1)
+		}
 
-			if message == "" {
-				message = err.Error()
-			} else {
-				message = message + "\n" + err.Error()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tGROUP\tNAME\tSTATE\tPING\tUPTIME (24H)\tMESSAGE")
+		for _, m := range page.Monitors {
+			ping, msg := "-", ""
+			if m.Heartbeat != nil {
+				msg = strings.ReplaceAll(m.Heartbeat.Msg, "\n", " ")
+				if m.Heartbeat.Ping != nil {
+					ping = fmt.Sprintf("%gms", *m.Heartbeat.Ping)
+				}
 			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.2f%%\t%s\n", m.ID, m.Group, m.Name, m.State(), ping, m.Uptime*100, msg)
+		}
+		w.Flush()
 
-			if !reverse {
-				statusUp = false
-			} else {
-				slog.Debug("Reverse is set, setting status to up")
+		for _, m := range page.Maintenances {
+			fmt.Printf("\nMaintenance active: %s\n", m.Title)
+			if m.Description != "" {
+				fmt.Println(m.Description)
 			}
+		}
+	},
+}
 
-			if onlySuccess {
-				slog.Debug("Only success is set, not sending status")
-				return
-			}
+// uptimeKumaInMaintenance checks the status page set with --maintenance-slug for
+// an active maintenance, errors are logged and count as no maintenance.
+func uptimeKumaInMaintenance(cmd *cobra.Command, instance string) bool {
+	slug, _ := cmd.Flags().GetString("maintenance-slug")
+	monitor, _ := cmd.Flags().GetString("maintenance-monitor")
+	if slug == "" {
+		return false
+	}
 
-			if err := uptimekuma.SendMonitorStatus(instance, token, statusUp, message, ""); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
+	page, err := uptimekuma.GetStatusPage(instance, slug)
+	if err != nil {
+		slog.Warn("Could not read status page, assuming no maintenance", "error", err)
+		return false
+	}
 
-			fmt.Println("Sent status to uptime-kuma")
-		} else {
-			if reverse {
-				slog.Debug("Reverse is set, setting status to down")
-				statusUp = false
-			}
+	inMaintenance, err := page.InMaintenance(monitor)
+	if err != nil {
+		slog.Warn("Could not check maintenance, assuming no maintenance", "error", err)
+		return false
+	}
 
-			if onlyFailure {
-				slog.Debug("Only error is set, not sending status")
-				return
-			}
+	return inMaintenance
+}
 
-			if err := uptimekuma.SendMonitorStatus(instance, token, statusUp, message, ""); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
+// measureUptimeKumaPing computes the ping from the wrapped command result or
+// from the stdout of the measurement command set with --ping-command.
+func measureUptimeKumaPing(cmd *cobra.Command, result *wrap.Result) (float64, error) {
+	pingFrom, _ := cmd.Flags().GetString("ping-from")
+	pingCommand, _ := cmd.Flags().GetString("ping-command")
+	pingPattern, _ := cmd.Flags().GetString("ping-pattern")
+
+	var pattern *regexp.Regexp
+	if pingPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(pingPattern); err != nil {
+			return 0, fmt.Errorf("invalid ping pattern: %w", err)
+		}
+	}
 
-			fmt.Println("Sent status to uptime-kuma")
+	if pingCommand != "" {
+		slog.Debug("Running ping measurement command", "command", pingCommand)
+		output, err := exec.Command("sh", "-c", pingCommand).Output()
+		if err != nil {
+			return 0, fmt.Errorf("ping command failed: %w", err)
 		}
-	},
+		return uptimekuma.ParsePing(string(output), pattern)
+	}
+
+	switch pingFrom {
+	case "duration":
+		return uptimekuma.PingFromDuration(result.Duration), nil
+	case "output":
+		return uptimekuma.ParsePing(result.Output, pattern)
+	}
+
+	return 0, fmt.Errorf("unknown ping source %q", pingFrom)
 }
 
 func ensureUptimeKumaConfigCorrect(cmd *cobra.Command) error {
@@ -178,6 +297,18 @@ func ensureUptimeKumaConfigCorrect(cmd *cobra.Command) error {
 	return nil
 }
 
+func ensureUptimeKumaStatusCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flag("instance").Changed {
+		return fmt.Errorf("You must set the instance")
+	}
+
+	if !cmd.Flag("slug").Changed {
+		return fmt.Errorf("You must set the slug of the status page")
+	}
+
+	return nil
+}
+
 func ensureUptimeKumaDefaultCmdConfigCorrect(cmd *cobra.Command) error {
 	if cmd.Flag("down").Changed && cmd.Flag("up").Changed {
 		return fmt.Errorf("You can't set both down and up")
@@ -185,6 +316,10 @@ func ensureUptimeKumaDefaultCmdConfigCorrect(cmd *cobra.Command) error {
 		return fmt.Errorf("You must set either down or up")
 	}
 
+	if cmd.Flag("ping").Changed && cmd.Flag("ping-command").Changed {
+		return fmt.Errorf("You can't set both ping and ping-command")
+	}
+
 	return nil
 }
 
@@ -193,27 +328,47 @@ func ensureUptimeKumaWrapCmdConfigCorrect(cmd *cobra.Command, args []string) err
 		return fmt.Errorf("You can't set both error and success")
 	}
 
+	if cmd.Flag("ping-from").Changed && cmd.Flag("ping-command").Changed {
+		return fmt.Errorf("You can't set both ping-from and ping-command")
+	} else if pingFrom, _ := cmd.Flags().GetString("ping-from"); cmd.Flag("ping-from").Changed && pingFrom != "duration" && pingFrom != "output" {
+		return fmt.Errorf("ping-from must be either duration or output")
+	}
+
+	if cmd.Flag("maintenance-monitor").Changed && !cmd.Flag("maintenance-slug").Changed {
+		return fmt.Errorf("You must set the maintenance-slug to check a maintenance-monitor")
+	}
+
 	if len(args) == 0 {
 		return fmt.Errorf("You must provide a command to wrap")
 	}
 
-	return nil
+	return ensureLockConfigCorrect(cmd)
 }
 
 func init() {
 	rootCmd.AddCommand(uptimeKumaCmd)
 	uptimeKumaCmd.AddCommand(uptimeKumaWrapCmd)
+	uptimeKumaCmd.AddCommand(uptimeKumaStatusCmd)
 
 	uptimeKumaCmd.PersistentFlags().StringP("instance", "i", "", "The instance to send the notification to")
 	uptimeKumaCmd.PersistentFlags().StringP("token", "t", "", "Token for the push monitor")
-	uptimeKumaCmd.Flags().StringP("message", "m", "", "Message")
-	uptimeKumaCmd.Flags().StringP("ping", "p", "", "Measurement number to send to the monitor")
+	uptimeKumaCmd.PersistentFlags().String("ping-command", "", "Command whose stdout is used as ping in milliseconds")
+	uptimeKumaCmd.PersistentFlags().String("ping-pattern", "", "Regex to extract the ping from the output, the first capture group is used")
+	uptimeKumaCmd.Flags().StringP("message", "m", "", "Message, - reads it from stdin")
+	addStdinFlags(uptimeKumaCmd, 1024)
+	uptimeKumaCmd.Flags().Float64P("ping", "p", 0, "Measurement in milliseconds to send to the monitor")
 	uptimeKumaCmd.Flags().Bool("down", false, "Set the monitor to down")
 	uptimeKumaCmd.Flags().Bool("up", false, "Set the monitor to up")
 
+	addLockFlags(uptimeKumaWrapCmd)
 	uptimeKumaWrapCmd.Flags().Bool("fail", false, "Send a notification only if the command fails")
 	uptimeKumaWrapCmd.Flags().Bool("success", false, "Send a notification only if the command succeeds")
 	uptimeKumaWrapCmd.Flags().Bool("reverse", false, "Send a up notification if the command fails and a down notification if the command succeeds")
 	uptimeKumaWrapCmd.Flags().StringP("message", "m", "", "Message before stdout/stderr")
 	uptimeKumaWrapCmd.Flags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+	uptimeKumaWrapCmd.Flags().String("ping-from", "", "Compute the ping from the command (duration, output)")
+	uptimeKumaWrapCmd.Flags().String("maintenance-slug", "", "Status page to check for an active maintenance before sending a down status")
+	uptimeKumaWrapCmd.Flags().String("maintenance-monitor", "", "Name or ID of the monitor on the status page to check for maintenance")
+
+	uptimeKumaStatusCmd.Flags().String("slug", "", "Slug of the status page")
 }
@@ -0,0 +1,169 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	uptimekuma "github.com/rwxd/notify-me/services/uptimeKuma"
+	"github.com/spf13/cobra"
+)
+
+var ntfyWaitPidCmd = &cobra.Command{
+	Use:   "wait-pid PID",
+	Short: "Wait for a running process to end and send a push notification to a ntfy instance",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureNtfyConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureWaitPidCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		instance, _ := cmd.Flags().GetString("instance")
+		tags, _ := cmd.Flags().GetStringSlice("tags")
+
+		instance = withScheme(instance)
+
+		result := waitForPid(cmd, args[0])
+
+		notification := ntfy.NewNotification(cmd.Flags().Lookup("topic").Value.String(),
+			cmd.Flags().Lookup("title").Value.String(),
+			waitPidMessage(cmd, args[0], result),
+			ntfy.Priority(cmd.Flags().Lookup("priority").Value.String()),
+			tags,
+			cmd.Flags().Lookup("url").Value.String(),
+			cmd.Flags().Lookup("actions").Value.String(),
+			cmd.Flags().Lookup("delay").Value.String(),
+			cmd.Flags().Lookup("icon").Value.String(),
+			cmd.Flags().Lookup("markdown").Changed,
+		)
+
+		if !applyNtfyQuietHours(cmd, notification) || !applyNtfyLimits(cmd, instance, notification) {
+			return
+		}
+
+		sendNtfyNotification(cmd, instance, notification)
+	},
+}
+
+var uptimeKumaWaitPidCmd = &cobra.Command{
+	Use:   "wait-pid PID",
+	Short: "Wait for a running process to end and send an up status to an uptime-kuma instance",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureUptimeKumaConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureWaitPidCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		instance, _ := cmd.Flags().GetString("instance")
+		token, _ := cmd.Flags().GetString("token")
+
+		result := waitForPid(cmd, args[0])
+
+		push := uptimekuma.NewPushRequest(true, waitPidMessage(cmd, args[0], result)).
+			WithPing(uptimekuma.PingFromDuration(result.Duration))
+		if err := uptimekuma.SendPush(withScheme(instance), token, push); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Sent status to uptime-kuma")
+	},
+}
+
+// waitForPid waits for the process and exits if it doesn't exist.
+func waitForPid(cmd *cobra.Command, arg string) *wrap.Result {
+	poll, _ := cmd.Flags().GetDuration("poll")
+	pid, _ := strconv.Atoi(arg)
+
+	result, err := wrap.WaitPID(pid, poll)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return result
+}
+
+// waitPidMessage is the message like wrap builds it, with the runtime of the
+// process instead of the output, which is unknown like the exit status.
+func waitPidMessage(cmd *cobra.Command, pid string, result *wrap.Result) string {
+	message, _ := cmd.Flags().GetString("message")
+	onlyMessage, _ := cmd.Flags().GetBool("only-message")
+
+	summary := fmt.Sprintf("Process %s (%s) ended after %s", pid, result.Command(), result.Duration.Round(time.Second))
+	if onlyMessage {
+		return message
+	} else if message != "" {
+		return message + "\n" + summary
+	}
+
+	return summary
+}
+
+func ensureWaitPidCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one PID must be provided")
+	}
+
+	if pid, err := strconv.Atoi(args[0]); err != nil || pid <= 0 {
+		return errors.New("PID must be a positive number")
+	}
+
+	if poll, _ := cmd.Flags().GetDuration("poll"); poll <= 0 {
+		return errors.New("poll must be positive")
+	}
+
+	return nil
+}
+
+func init() {
+	ntfyCmd.AddCommand(ntfyWaitPidCmd)
+	uptimeKumaCmd.AddCommand(uptimeKumaWaitPidCmd)
+
+	for _, c := range []*cobra.Command{ntfyWaitPidCmd, uptimeKumaWaitPidCmd} {
+		c.Flags().StringP("message", "m", "", "Message, before the runtime of the process")
+		c.Flags().Bool("only-message", false, "Only send the custom message, without the runtime of the process")
+		c.Flags().Duration("poll", time.Second, "How often to check the process where it can't be waited for")
+	}
+}
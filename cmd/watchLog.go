@@ -0,0 +1,257 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/logwatch"
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/target"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+var watchLogCmd = &cobra.Command{
+	Use:   "watch-log",
+	Short: "Follow a log file and send notifications for lines matching patterns",
+	Long: `Follow a log file like tail -F and send notifications for lines matching
+patterns. Matches within the window are sent as one notification, with lines
+of context around them. The file is followed across rotation.
+
+--to is ntfy, configured with the ntfy flags, or a target URL like with
+"notify-me send".`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureWatchLogCmdConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		path, _ := cmd.Flags().GetString("file")
+		poll, _ := cmd.Flags().GetDuration("poll")
+		fromStart, _ := cmd.Flags().GetBool("from-start")
+		window, _ := cmd.Flags().GetDuration("window")
+		contextLines, _ := cmd.Flags().GetInt("context")
+
+		matcher, _ := watchLogMatcher(cmd)
+		collector := &logwatch.Collector{Matcher: matcher, Context: contextLines}
+		follower := &logwatch.Follower{Path: path, Poll: poll, FromStart: fromStart}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		lines := make(chan string)
+		errs := make(chan error, 1)
+		go func() { errs <- follower.Follow(ctx, lines) }()
+
+		fmt.Printf("Watching %s\n", path)
+		var flush <-chan time.Time
+		for {
+			select {
+			case line := <-lines:
+				if collector.Add(line) && flush == nil {
+					flush = time.After(window)
+				}
+			case <-flush:
+				flush = nil
+				sendWatchLogMatches(cmd, collector)
+			case err := <-errs:
+				sendWatchLogMatches(cmd, collector)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				return
+			}
+		}
+	},
+}
+
+// watchLogMatcher builds the matcher of --match with the default priority,
+// --priority-match and --exclude.
+func watchLogMatcher(cmd *cobra.Command) (*logwatch.Matcher, error) {
+	matches, _ := cmd.Flags().GetStringArray("match")
+	priorityMatches, _ := cmd.Flags().GetStringArray("priority-match")
+	excludes, _ := cmd.Flags().GetStringArray("exclude")
+	priority, _ := cmd.Flags().GetString("priority")
+
+	if !ntfy.Priority(priority).Valid() {
+		return nil, errors.New("priority must be min, low, default, high, max or 1 to 5")
+	}
+
+	m := &logwatch.Matcher{}
+	for _, expr := range matches {
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern: %w", err)
+		}
+		m.Patterns = append(m.Patterns, logwatch.Pattern{Regexp: r, Priority: ntfy.Priority(priority)})
+	}
+	for _, pm := range priorityMatches {
+		p, expr, ok := strings.Cut(pm, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid priority match %q, expected priority=pattern", pm)
+		}
+		if p == "" || !ntfy.Priority(p).Valid() {
+			return nil, fmt.Errorf("invalid priority match %q, priority must be min, low, default, high, max or 1 to 5", pm)
+		}
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern: %w", err)
+		}
+		m.Patterns = append(m.Patterns, logwatch.Pattern{Regexp: r, Priority: ntfy.Priority(p)})
+	}
+	for _, expr := range excludes {
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+		m.Excludes = append(m.Excludes, r)
+	}
+
+	return m, nil
+}
+
+// sendWatchLogMatches sends the collected matches as one notification,
+// errors are printed so watching goes on.
+func sendWatchLogMatches(cmd *cobra.Command, collector *logwatch.Collector) {
+	if collector.Matches() == 0 {
+		return
+	}
+
+	path, _ := cmd.Flags().GetString("file")
+	to, _ := cmd.Flags().GetString("to")
+	title, _ := cmd.Flags().GetString("title")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	maxSize, _ := cmd.Flags().GetInt("max-size")
+
+	groups, matches, priority := collector.Flush()
+	blocks := make([]string, 0, len(groups))
+	for _, g := range groups {
+		blocks = append(blocks, strings.Join(g, "\n"))
+	}
+	lines := "lines"
+	if matches == 1 {
+		lines = "line"
+	}
+	if title == "" {
+		title = fmt.Sprintf("%d matching %s in %s", matches, lines, filepath.Base(path))
+	}
+
+	notification := ntfy.NewNotification(cmd.Flags().Lookup("topic").Value.String(),
+		title,
+		wrap.Tail(strings.Join(blocks, "\n--\n"), maxSize),
+		priority,
+		tags,
+		"", "", "", "", false,
+	)
+
+	var err error
+	if to == "ntfy" {
+		err = ntfy.SendNotification(
+			notification,
+			withScheme(cmd.Flags().Lookup("instance").Value.String()),
+			cmd.Flags().Lookup("user").Value.String(),
+			cmd.Flags().Lookup("pass").Value.String(),
+			cmd.Flags().Lookup("token").Value.String(),
+		)
+	} else {
+		t, _ := target.Parse(to)
+		err = t.Send(notify.NewEvent(notification.Title, notification.Message, notification.Priority, notification.Tags, ""))
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	slog.Debug("Sent matches", "matches", matches)
+	fmt.Printf("Notification sent for %d matching %s\n", matches, lines)
+}
+
+func ensureWatchLogCmdConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("file") {
+		return errors.New("file must be provided")
+	}
+
+	matches, _ := cmd.Flags().GetStringArray("match")
+	priorityMatches, _ := cmd.Flags().GetStringArray("priority-match")
+	if len(matches) == 0 && len(priorityMatches) == 0 {
+		return errors.New("at least one match or priority-match must be provided")
+	}
+	if _, err := watchLogMatcher(cmd); err != nil {
+		return err
+	}
+
+	if to, _ := cmd.Flags().GetString("to"); to == "ntfy" {
+		if !cmd.Flags().Changed("topic") {
+			return errors.New("topic must be provided")
+		} else if cmd.Flags().Changed("user") != cmd.Flags().Changed("pass") {
+			return errors.New("username and password must be provided together")
+		}
+	} else if _, err := target.Parse(to); err != nil {
+		return err
+	}
+
+	if poll, _ := cmd.Flags().GetDuration("poll"); poll <= 0 {
+		return errors.New("poll must be positive")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(watchLogCmd)
+
+	watchLogCmd.Flags().StringP("file", "f", "", "Log file to follow")
+	watchLogCmd.Flags().StringArray("match", []string{}, "Regular expression of lines to notify about, can be repeated")
+	watchLogCmd.Flags().StringArray("priority-match", []string{}, "Priority and regular expression like max=panic, can be repeated")
+	watchLogCmd.Flags().StringArray("exclude", []string{}, "Regular expression of lines to ignore, can be repeated")
+	watchLogCmd.Flags().Duration("window", 10*time.Second, "Matches within this duration are sent as one notification")
+	watchLogCmd.Flags().IntP("context", "C", 2, "Lines of context before and after a matching line")
+	watchLogCmd.Flags().Bool("from-start", false, "Read the existing lines of the file too")
+	watchLogCmd.Flags().Duration("poll", time.Second, "How often the file is checked for new lines")
+	watchLogCmd.Flags().Int("max-size", 4000, "Maximum size of the message in bytes, only the last lines are kept")
+	watchLogCmd.Flags().String("to", "ntfy", "ntfy or a target URL to send notifications to")
+	watchLogCmd.Flags().StringP("instance", "i", "ntfy.sh", "ntfy instance")
+	watchLogCmd.Flags().StringP("user", "u", "", "Username for the ntfy instance")
+	watchLogCmd.Flags().StringP("pass", "p", "", "Password for the ntfy instance")
+	watchLogCmd.Flags().String("token", "", "Access token for the ntfy instance")
+	watchLogCmd.Flags().StringP("topic", "t", "", "Topic to send the message to")
+	watchLogCmd.Flags().StringP("title", "T", "", "Message title (default is the number of matching lines)")
+	watchLogCmd.Flags().StringP("priority", "P", "", "Priority of --match lines (min, low, default, high, max)")
+	watchLogCmd.Flags().StringSlice("tags", []string{}, "Tags for the message")
+}
@@ -0,0 +1,197 @@
+/*
+Copyright © 2024 rwxd
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/rwxd/notify-me/services/webhook"
+	"github.com/sagikazarmark/slog-shim"
+	"github.com/spf13/cobra"
+)
+
+// webhookDefaultTemplate sends all fields of the event as JSON object.
+const webhookDefaultTemplate = "{{ json .Fields }}"
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Send a notification as HTTP request with a templated body",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureWebhookConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+
+		if err := sendWebhook(cmd, newWebhookEvent(cmd, message)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Webhook sent")
+	},
+}
+
+var webhookWrapCmd = &cobra.Command{
+	Use:   "wrap",
+	Short: "Wrap a command and send the result as HTTP request with a templated body",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureWebhookConfigCorrect(cmd); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		} else if err := ensureWebhookWrapCmdConfigCorrect(cmd, args); err != nil {
+			fmt.Println(err)
+			cmd.Help()
+			os.Exit(1)
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		onlyFailure, _ := cmd.Flags().GetBool("fail")
+		onlySuccess, _ := cmd.Flags().GetBool("success")
+		onlyMessage, _ := cmd.Flags().GetBool("only-message")
+
+		result := wrap.Run(args[0], args[1:]...)
+
+		if result.Failed() && onlySuccess {
+			slog.Debug("Only sending on success, not sending webhook")
+			return
+		} else if !result.Failed() && onlyFailure {
+			slog.Debug("Only sending on failure, not sending webhook")
+			return
+		}
+
+		event := newWebhookEvent(cmd, result.Message(message, onlyMessage)).WithResult(result)
+		if err := sendWebhook(cmd, event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Webhook sent")
+	},
+}
+
+func newWebhookEvent(cmd *cobra.Command, message string) *notify.Event {
+	title, _ := cmd.Flags().GetString("title")
+	priority, _ := cmd.Flags().GetString("priority")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	url, _ := cmd.Flags().GetString("click")
+
+	return notify.NewEvent(title, message, ntfy.Priority(priority), tags, url)
+}
+
+// sendWebhook renders the body template against the event and sends it.
+func sendWebhook(cmd *cobra.Command, event *notify.Event) error {
+	url, _ := cmd.Flags().GetString("url")
+	method, _ := cmd.Flags().GetString("method")
+	headerFlags, _ := cmd.Flags().GetStringArray("header")
+	bodyTemplateFile, _ := cmd.Flags().GetString("body-template")
+	retries, _ := cmd.Flags().GetInt("retries")
+
+	tmpl := webhookDefaultTemplate
+	if bodyTemplateFile != "" {
+		content, err := os.ReadFile(bodyTemplateFile)
+		if err != nil {
+			return err
+		}
+		tmpl = string(content)
+	}
+
+	body, err := event.Render(tmpl)
+	if err != nil {
+		return fmt.Errorf("failed to render body template: %w", err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	for _, h := range headerFlags {
+		key, value, _ := strings.Cut(h, ":")
+		headers.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	return webhook.Send(webhook.NewRequest(url, strings.ToUpper(method), headers, body), retry.Policy{Attempts: retries + 1, Backoff: time.Second})
+}
+
+func ensureWebhookConfigCorrect(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("url") {
+		return errors.New("url must be provided")
+	}
+
+	headers, _ := cmd.Flags().GetStringArray("header")
+	for _, h := range headers {
+		if key, _, found := strings.Cut(h, ":"); !found || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("header %q must be in the format Key:Value", h)
+		}
+	}
+
+	return nil
+}
+
+func ensureWebhookWrapCmdConfigCorrect(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("fail") && cmd.Flags().Changed("success") {
+		return errors.New("only one of fail or success can be provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New("command must be provided")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(webhookCmd)
+	webhookCmd.AddCommand(webhookWrapCmd)
+
+	webhookCmd.PersistentFlags().String("url", "", "URL to send the request to")
+	webhookCmd.PersistentFlags().StringP("method", "X", "POST", "HTTP method")
+	webhookCmd.PersistentFlags().StringArrayP("header", "H", []string{}, "Header in the format Key:Value, can be repeated")
+	webhookCmd.PersistentFlags().String("body-template", "", "File with a Go template for the body, the default sends all fields as JSON")
+	webhookCmd.PersistentFlags().Int("retries", 2, "Number of retries on network errors, 429 and 5xx responses")
+	webhookCmd.PersistentFlags().StringP("message", "m", "", "Message")
+	webhookCmd.PersistentFlags().StringP("title", "T", "", "Message title")
+	webhookCmd.PersistentFlags().StringP("priority", "P", "", "Message Priority (min, low, default, high, max)")
+	webhookCmd.PersistentFlags().StringSlice("tags", []string{}, "Tags for the message")
+	webhookCmd.PersistentFlags().String("click", "", "URL to open when the notification is clicked")
+
+	webhookWrapCmd.Flags().Bool("fail", false, "Send a request only if the command fails")
+	webhookWrapCmd.Flags().Bool("success", false, "Send a request only if the command succeeds")
+	webhookWrapCmd.PersistentFlags().StringP("message", "m", "", "Message, before stdout/stderr")
+	webhookWrapCmd.PersistentFlags().Bool("only-message", false, "Only send the custom message, no stdout/stderr")
+}
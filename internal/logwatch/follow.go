@@ -0,0 +1,127 @@
+// Package logwatch follows log files and collects lines matching patterns
+// for notifications.
+package logwatch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Follower reads lines appended to a file, like tail -F. It follows the
+// path across rotation, when the file is replaced or truncated.
+type Follower struct {
+	Path string
+	Poll time.Duration
+	// FromStart reads the existing content first, otherwise only new lines
+	// are read.
+	FromStart bool
+}
+
+// Follow sends the lines of the file to lines until the context is done.
+func (f *Follower) Follow(ctx context.Context, lines chan<- string) error {
+	file, err := f.open(ctx, !f.FromStart)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+
+	reader := bufio.NewReader(file)
+	var partial string
+	// flush sends the last line of the old file when it had no newline
+	flush := func() bool {
+		if partial == "" {
+			return true
+		}
+		select {
+		case lines <- strings.TrimRight(partial, "\r\n"):
+		case <-ctx.Done():
+			return false
+		}
+		partial = ""
+		return true
+	}
+	for {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				partial += line
+				break
+			}
+			select {
+			case lines <- strings.TrimRight(partial+line, "\r\n"):
+			case <-ctx.Done():
+				return nil
+			}
+			partial = ""
+		}
+
+		current, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(f.Path); err == nil && !os.SameFile(info, current) {
+			// the old file was read to the end, the new one is read from the start
+			slog.Debug("Log file was rotated", "path", f.Path)
+			if !flush() {
+				return nil
+			}
+			file.Close()
+			if file, err = f.open(ctx, false); err != nil {
+				return err
+			}
+			reader.Reset(file)
+			continue
+		} else if current.Size() < offset {
+			slog.Debug("Log file was truncated", "path", f.Path)
+			if !flush() {
+				return nil
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			reader.Reset(file)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(f.Poll):
+		}
+	}
+}
+
+// open opens the file, waiting for it to exist. A file that didn't exist
+// yet is read from the start, all of it is new.
+func (f *Follower) open(ctx context.Context, atEnd bool) (*os.File, error) {
+	for {
+		file, err := os.Open(f.Path)
+		if err == nil {
+			if atEnd {
+				_, err = file.Seek(0, io.SeekEnd)
+			}
+			return file, err
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		atEnd = false
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.Poll):
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package logwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// follow runs a Follower on path while steps change the file, and returns
+// the lines read once the last step's lines arrived.
+func follow(t *testing.T, path string, fromStart bool, steps []func(), want int) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := &Follower{Path: path, Poll: 10 * time.Millisecond, FromStart: fromStart}
+	lines := make(chan string)
+	done := make(chan error, 1)
+	go func() { done <- f.Follow(ctx, lines) }()
+
+	// let the follower open the file before it changes
+	time.Sleep(50 * time.Millisecond)
+
+	var got []string
+	for _, step := range steps {
+		step()
+		// give the follower time to see this step before the next one
+		time.Sleep(50 * time.Millisecond)
+	}
+	for len(got) < want {
+		select {
+		case line := <-lines:
+			got = append(got, line)
+		case <-ctx.Done():
+			t.Fatalf("got %q, want %d lines", got, want)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestFollow(t *testing.T) {
+	tests := []struct {
+		name      string
+		initial   string
+		fromStart bool
+		steps     func(path string) []func()
+		want      []string
+	}{
+		{
+			name:    "only new lines",
+			initial: "old\n",
+			steps: func(path string) []func() {
+				return []func(){func() { appendFile(t, path, "new 1\nnew 2\n") }}
+			},
+			want: []string{"new 1", "new 2"},
+		},
+		{
+			name:      "from the start",
+			initial:   "old\n",
+			fromStart: true,
+			steps: func(path string) []func() {
+				return []func(){func() { appendFile(t, path, "new\r\n") }}
+			},
+			want: []string{"old", "new"},
+		},
+		{
+			name: "line written in parts",
+			steps: func(path string) []func() {
+				return []func(){
+					func() { appendFile(t, path, "first ") },
+					func() { appendFile(t, path, "half\n") },
+				}
+			},
+			want: []string{"first half"},
+		},
+		{
+			name: "rotation",
+			steps: func(path string) []func() {
+				return []func(){
+					func() { appendFile(t, path, "before\n") },
+					func() {
+						if err := os.Rename(path, path+".1"); err != nil {
+							t.Fatal(err)
+						}
+						writeFile(t, path, "after\n")
+					},
+				}
+			},
+			want: []string{"before", "after"},
+		},
+		{
+			name: "rotation keeps a last line without newline",
+			steps: func(path string) []func() {
+				return []func(){
+					func() { appendFile(t, path, "complete\nunfinished") },
+					func() {
+						if err := os.Rename(path, path+".1"); err != nil {
+							t.Fatal(err)
+						}
+						writeFile(t, path, "after\n")
+					},
+				}
+			},
+			want: []string{"complete", "unfinished", "after"},
+		},
+		{
+			name:    "truncation",
+			initial: "old line that is long\n",
+			steps: func(path string) []func() {
+				return []func(){
+					func() { appendFile(t, path, "before\n") },
+					func() { writeFile(t, path, "after\n") },
+				}
+			},
+			want: []string{"before", "after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			writeFile(t, path, tt.initial)
+
+			got := follow(t, path, tt.fromStart, tt.steps(path), len(tt.want))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowWaitsForFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	got := follow(t, path, false, []func(){func() { writeFile(t, path, "created\n") }}, 1)
+	if want := []string{"created"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func writeFile(t *testing.T, path, s string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
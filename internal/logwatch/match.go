@@ -0,0 +1,99 @@
+package logwatch
+
+import (
+	"regexp"
+
+	"github.com/rwxd/notify-me/services/ntfy"
+)
+
+// Pattern is a pattern lines match with the priority of their notification.
+type Pattern struct {
+	Regexp   *regexp.Regexp
+	Priority ntfy.Priority
+}
+
+// Matcher matches lines against patterns, lines matching an exclude pattern
+// never match.
+type Matcher struct {
+	Patterns []Pattern
+	Excludes []*regexp.Regexp
+}
+
+// Match returns the highest priority of the patterns the line matches.
+func (m *Matcher) Match(line string) (ntfy.Priority, bool) {
+	for _, e := range m.Excludes {
+		if e.MatchString(line) {
+			return "", false
+		}
+	}
+
+	var priority ntfy.Priority
+	matched := false
+	for _, p := range m.Patterns {
+		if p.Regexp.MatchString(line) && (!matched || p.Priority.Level() > priority.Level()) {
+			priority, matched = p.Priority, true
+		}
+	}
+
+	return priority, matched
+}
+
+// Collector groups matching lines with lines of context around them, like
+// grep -C.
+type Collector struct {
+	Matcher *Matcher
+	Context int
+
+	before   []string
+	after    int
+	groups   [][]string
+	matches  int
+	priority ntfy.Priority
+}
+
+// Add adds a line and reports whether it matched.
+func (c *Collector) Add(line string) bool {
+	priority, matched := c.Matcher.Match(line)
+
+	switch {
+	case matched && c.after > 0 && len(c.groups) > 0:
+		c.groups[len(c.groups)-1] = append(c.groups[len(c.groups)-1], line)
+	case matched:
+		c.groups = append(c.groups, append(c.before, line))
+		c.before = nil
+	case c.after > 0 && len(c.groups) > 0:
+		c.groups[len(c.groups)-1] = append(c.groups[len(c.groups)-1], line)
+		c.after--
+		return false
+	default:
+		if c.Context > 0 {
+			c.before = append(c.before, line)
+			if len(c.before) > c.Context {
+				c.before = c.before[1:]
+			}
+		}
+		return false
+	}
+
+	c.after = c.Context
+	if c.matches == 0 || priority.Level() > c.priority.Level() {
+		c.priority = priority
+	}
+	c.matches++
+
+	return true
+}
+
+// Matches is the number of matching lines since the last flush.
+func (c *Collector) Matches() int {
+	return c.matches
+}
+
+// Flush returns the collected groups, the number of matching lines and
+// their highest priority, and starts over.
+func (c *Collector) Flush() ([][]string, int, ntfy.Priority) {
+	groups, matches, priority := c.groups, c.matches, c.priority
+	c.groups, c.matches, c.priority, c.after = nil, 0, "", 0
+
+	return groups, matches, priority
+}
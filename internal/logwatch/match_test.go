@@ -0,0 +1,145 @@
+package logwatch
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/rwxd/notify-me/services/ntfy"
+)
+
+func testMatcher() *Matcher {
+	return &Matcher{
+		Patterns: []Pattern{
+			{Regexp: regexp.MustCompile(`ERROR`), Priority: ntfy.PriorityHigh},
+			{Regexp: regexp.MustCompile(`WARN|ERROR`), Priority: ntfy.PriorityDefault},
+			{Regexp: regexp.MustCompile(`panic`), Priority: ntfy.PriorityMax},
+		},
+		Excludes: []*regexp.Regexp{regexp.MustCompile(`healthcheck`)},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		line     string
+		priority ntfy.Priority
+		matched  bool
+	}{
+		{line: "INFO started", matched: false},
+		{line: "WARN disk almost full", priority: ntfy.PriorityDefault, matched: true},
+		{line: "ERROR connection refused", priority: ntfy.PriorityHigh, matched: true},
+		{line: "ERROR panic: nil map", priority: ntfy.PriorityMax, matched: true},
+		{line: "ERROR healthcheck failed", matched: false},
+	}
+
+	m := testMatcher()
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			priority, matched := m.Match(tt.line)
+			if matched != tt.matched || priority != tt.priority {
+				t.Errorf("Match(%q) = %q, %t, want %q, %t", tt.line, priority, matched, tt.priority, tt.matched)
+			}
+		})
+	}
+}
+
+func TestCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		context  int
+		lines    []string
+		groups   [][]string
+		matches  int
+		priority ntfy.Priority
+	}{
+		{
+			name:  "no match",
+			lines: []string{"a", "b"},
+		},
+		{
+			name:     "without context",
+			lines:    []string{"a", "ERROR 1", "b", "WARN 2", "c"},
+			groups:   [][]string{{"ERROR 1"}, {"WARN 2"}},
+			matches:  2,
+			priority: ntfy.PriorityHigh,
+		},
+		{
+			name:     "context around a match",
+			context:  1,
+			lines:    []string{"a", "b", "ERROR 1", "c", "d"},
+			groups:   [][]string{{"b", "ERROR 1", "c"}},
+			matches:  1,
+			priority: ntfy.PriorityHigh,
+		},
+		{
+			name:     "overlapping context is one group",
+			context:  2,
+			lines:    []string{"a", "WARN 1", "b", "WARN 2", "c", "d", "e"},
+			groups:   [][]string{{"a", "WARN 1", "b", "WARN 2", "c", "d"}},
+			matches:  2,
+			priority: ntfy.PriorityDefault,
+		},
+		{
+			name:     "separate groups",
+			context:  1,
+			lines:    []string{"a", "WARN 1", "b", "c", "d", "panic 2", "e"},
+			groups:   [][]string{{"a", "WARN 1", "b"}, {"d", "panic 2", "e"}},
+			matches:  2,
+			priority: ntfy.PriorityMax,
+		},
+		{
+			name:     "context at the start",
+			context:  3,
+			lines:    []string{"ERROR 1", "a"},
+			groups:   [][]string{{"ERROR 1", "a"}},
+			matches:  1,
+			priority: ntfy.PriorityHigh,
+		},
+		{
+			name:     "excluded lines are context",
+			context:  1,
+			lines:    []string{"ERROR healthcheck", "ERROR 1"},
+			groups:   [][]string{{"ERROR healthcheck", "ERROR 1"}},
+			matches:  1,
+			priority: ntfy.PriorityHigh,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Collector{Matcher: testMatcher(), Context: tt.context}
+			for _, line := range tt.lines {
+				c.Add(line)
+			}
+			if c.Matches() != tt.matches {
+				t.Errorf("Matches() = %d, want %d", c.Matches(), tt.matches)
+			}
+
+			groups, matches, priority := c.Flush()
+			if !reflect.DeepEqual(groups, tt.groups) {
+				t.Errorf("groups = %q, want %q", groups, tt.groups)
+			}
+			if matches != tt.matches || priority != tt.priority {
+				t.Errorf("Flush() = %d, %q, want %d, %q", matches, priority, tt.matches, tt.priority)
+			}
+		})
+	}
+}
+
+func TestCollectorFlushStartsOver(t *testing.T) {
+	c := &Collector{Matcher: testMatcher(), Context: 1}
+	for _, line := range []string{"a", "panic 1", "b"} {
+		c.Add(line)
+	}
+	c.Flush()
+
+	for _, line := range []string{"c", "WARN 2"} {
+		c.Add(line)
+	}
+	groups, matches, priority := c.Flush()
+
+	want := [][]string{{"c", "WARN 2"}}
+	if !reflect.DeepEqual(groups, want) || matches != 1 || priority != ntfy.PriorityDefault {
+		t.Errorf("Flush() = %q, %d, %q, want %q, 1, %q", groups, matches, priority, want, ntfy.PriorityDefault)
+	}
+}
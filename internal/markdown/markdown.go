@@ -0,0 +1,22 @@
+// Package markdown renders markdown message bodies for backends that expect HTML.
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// ToHTML renders GitHub flavored markdown to HTML. Raw HTML in the source is
+// not passed through.
+func ToHTML(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,136 @@
+// Package notify holds the backend independent model of a notification.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/wrap"
+	"github.com/rwxd/notify-me/services/ntfy"
+)
+
+// Event is what a notification is about, the data the ntfy notification
+// carries independent of the backend. It is also the data templates are
+// rendered against.
+type Event struct {
+	Title    string
+	Message  string
+	Priority ntfy.Priority
+	Tags     []string
+	Url      string
+	Host     string
+	Time     time.Time
+	// Result is the wrapped command, nil if nothing was wrapped.
+	Result *wrap.Result
+}
+
+func NewEvent(title, message string, priority ntfy.Priority, tags []string, url string) *Event {
+	host, _ := os.Hostname()
+
+	return &Event{
+		Title:    title,
+		Message:  message,
+		Priority: priority,
+		Tags:     tags,
+		Url:      url,
+		Host:     host,
+		Time:     time.Now(),
+	}
+}
+
+// WithResult attaches the result of a wrapped command.
+func (e *Event) WithResult(result *wrap.Result) *Event {
+	e.Result = result
+	if result.Host != "" {
+		e.Host = result.Host
+	}
+
+	return e
+}
+
+// Status is "success" or "failure" for wrapped commands, empty otherwise.
+func (e *Event) Status() string {
+	if e.Result == nil {
+		return ""
+	} else if e.Result.Failed() {
+		return "failure"
+	}
+
+	return "success"
+}
+
+// Render executes tmpl as text/template against the event.
+func (e *Event) Render(tmpl string) (string, error) {
+	t, err := template.New("").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, e); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Fields returns the event as flat map with snake_case keys, for backends
+// sending structured data.
+func (e *Event) Fields() map[string]any {
+	fields := map[string]any{
+		"title":    e.Title,
+		"message":  e.Message,
+		"priority": string(e.Priority),
+		"tags":     e.Tags,
+		"url":      e.Url,
+		"host":     e.Host,
+		"time":     e.Time.Format(time.RFC3339),
+	}
+	if e.Tags == nil {
+		fields["tags"] = []string{}
+	}
+	if e.Result != nil {
+		fields["status"] = e.Status()
+		fields["command"] = e.Result.Command()
+		fields["exit_code"] = e.Result.ExitCode
+		fields["duration_seconds"] = e.Result.Duration.Seconds()
+		fields["output"] = e.Result.Output
+	}
+
+	return fields
+}
+
+// StringFields is Fields with the values formatted as strings, tags are
+// joined with commas and empty values are left out.
+func (e *Event) StringFields() map[string]string {
+	fields := map[string]string{}
+	for name, value := range e.Fields() {
+		var s string
+		switch v := value.(type) {
+		case []string:
+			s = strings.Join(v, ",")
+		default:
+			s = fmt.Sprint(v)
+		}
+		if s != "" {
+			fields[name] = s
+		}
+	}
+
+	return fields
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value, strings included, for use in JSON bodies: {{ json .Message }}
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// tail keeps the end of a string, as pipeline: {{ .Result.Output | tail 1000 }}
+	"tail": func(max int, s string) string { return wrap.Tail(s, max) },
+}
@@ -0,0 +1,174 @@
+// Package ratelimit limits how often notifications are sent, shared by all
+// invocations on the host through a locked state file.
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwxd/notify-me/internal/state"
+)
+
+// Limits of a target. Rate notifications per Per are sent at most, with
+// bursts up to Rate. Identical notifications within DedupWindow of the
+// first one are suppressed. Zero values disable the limits.
+type Limits struct {
+	Rate        int
+	Per         time.Duration
+	DedupWindow time.Duration
+}
+
+// ParseRate parses a rate like "10/m", "100/h" or "5/30s".
+func ParseRate(s string) (int, time.Duration, error) {
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate %q, expected count/period like 10/m", s)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return 0, 0, fmt.Errorf("invalid rate %q, expected count/period like 10/m", s)
+	}
+
+	if period == "s" || period == "m" || period == "h" {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate %q, expected count/period like 10/m", s)
+	}
+
+	return n, d, nil
+}
+
+type bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+	// Dropped counts the notifications dropped since the last sent one.
+	Dropped int `json:"dropped"`
+}
+
+type seen struct {
+	Sent    time.Time `json:"sent"`
+	Expires time.Time `json:"expires"`
+	// Repeated counts the suppressed duplicates since Sent.
+	Repeated int `json:"repeated"`
+}
+
+type file struct {
+	Buckets map[string]*bucket `json:"buckets"`
+	Seen    map[string]*seen   `json:"seen"`
+}
+
+// Decision is whether a notification is sent and what was held back before.
+type Decision struct {
+	Allow bool
+	// Reason is why a notification is not sent.
+	Reason string
+	// Dropped is the number of notifications to the target dropped by the
+	// rate limit since the last sent one.
+	Dropped int
+	// Repeated is the number of suppressed duplicates since the last time
+	// this notification was sent, at Since.
+	Repeated int
+	Since    time.Time
+}
+
+// Fingerprint identifies identical notifications.
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Check decides whether the notification with the fingerprint may be sent
+// to the target now and records it.
+func Check(target, fingerprint string, l Limits, now time.Time) (Decision, error) {
+	var d Decision
+	f := &file{}
+
+	err := state.Update("ratelimit", f, func() error {
+		if f.Buckets == nil {
+			f.Buckets = map[string]*bucket{}
+		}
+		if f.Seen == nil {
+			f.Seen = map[string]*seen{}
+		}
+		prune(f, now)
+
+		key := target + "\x00" + fingerprint
+		s := f.Seen[key]
+		if l.DedupWindow > 0 && s != nil && now.Sub(s.Sent) < l.DedupWindow {
+			s.Repeated++
+			d.Reason = fmt.Sprintf("identical notification sent at %s", s.Sent.Format(time.RFC3339))
+			return nil
+		}
+
+		b := f.Buckets[target]
+		if l.Rate > 0 {
+			if b == nil {
+				b = &bucket{Tokens: float64(l.Rate), Updated: now}
+				f.Buckets[target] = b
+			}
+			refill := now.Sub(b.Updated).Seconds() / l.Per.Seconds() * float64(l.Rate)
+			b.Tokens = min(b.Tokens+refill, float64(l.Rate))
+			b.Updated = now
+
+			if b.Tokens < 1 {
+				b.Dropped++
+				d.Reason = fmt.Sprintf("rate limit of %d per %s reached", l.Rate, l.Per)
+				return nil
+			}
+			b.Tokens--
+		}
+
+		d.Allow = true
+		if b != nil {
+			d.Dropped, b.Dropped = b.Dropped, 0
+		}
+		if s != nil {
+			d.Repeated, d.Since = s.Repeated, s.Sent
+		}
+		if l.DedupWindow > 0 {
+			f.Seen[key] = &seen{Sent: now, Expires: now.Add(l.DedupWindow)}
+		} else {
+			delete(f.Seen, key)
+		}
+
+		return nil
+	})
+
+	return d, err
+}
+
+// prune removes expired duplicates, with repeats after a week, and buckets
+// unused for a day.
+func prune(f *file, now time.Time) {
+	for key, s := range f.Seen {
+		if now.After(s.Expires) && (s.Repeated == 0 || now.Sub(s.Expires) > 7*24*time.Hour) {
+			delete(f.Seen, key)
+		}
+	}
+	for key, b := range f.Buckets {
+		if now.Sub(b.Updated) > 24*time.Hour && b.Dropped == 0 {
+			delete(f.Buckets, key)
+		}
+	}
+}
+
+// Summary describes what was held back before an allowed notification,
+// empty if nothing was.
+func (d Decision) Summary() string {
+	var lines []string
+	if d.Repeated > 0 {
+		lines = append(lines, fmt.Sprintf("Repeated %d times since %s", d.Repeated, d.Since.Format(time.RFC3339)))
+	}
+	if d.Dropped > 0 {
+		lines = append(lines, fmt.Sprintf("%d notifications dropped by the rate limit", d.Dropped))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,233 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		n       int
+		per     time.Duration
+		wantErr bool
+	}{
+		{in: "10/m", n: 10, per: time.Minute},
+		{in: "100/h", n: 100, per: time.Hour},
+		{in: "1/s", n: 1, per: time.Second},
+		{in: "5/30s", n: 5, per: 30 * time.Second},
+		{in: "3/1h30m", n: 3, per: 90 * time.Minute},
+		{in: "10", wantErr: true},
+		{in: "0/m", wantErr: true},
+		{in: "-1/m", wantErr: true},
+		{in: "x/m", wantErr: true},
+		{in: "10/d", wantErr: true},
+		{in: "10/0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			n, per, err := ParseRate(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRate(%q) = %d, %s, want error", tt.in, n, per)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRate(%q): %s", tt.in, err)
+			}
+			if n != tt.n || per != tt.per {
+				t.Errorf("ParseRate(%q) = %d, %s, want %d, %s", tt.in, n, per, tt.n, tt.per)
+			}
+		})
+	}
+}
+
+// step is one notification checked at an offset from the start.
+type step struct {
+	at          time.Duration
+	fingerprint string
+	allow       bool
+	dropped     int
+	repeated    int
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		steps  []step
+	}{
+		{
+			name:   "no limits",
+			limits: Limits{},
+			steps: []step{
+				{at: 0, fingerprint: "a", allow: true},
+				{at: 0, fingerprint: "a", allow: true},
+			},
+		},
+		{
+			name:   "burst up to the rate",
+			limits: Limits{Rate: 2, Per: time.Minute},
+			steps: []step{
+				{at: 0, fingerprint: "a", allow: true},
+				{at: 0, fingerprint: "b", allow: true},
+				{at: time.Second, fingerprint: "c", allow: false},
+				{at: 2 * time.Second, fingerprint: "d", allow: false},
+			},
+		},
+		{
+			name:   "tokens refill over the period",
+			limits: Limits{Rate: 2, Per: time.Minute},
+			steps: []step{
+				{at: 0, fingerprint: "a", allow: true},
+				{at: 0, fingerprint: "b", allow: true},
+				{at: 10 * time.Second, fingerprint: "c", allow: false},
+				{at: 30 * time.Second, fingerprint: "d", allow: true, dropped: 1},
+				{at: 31 * time.Second, fingerprint: "e", allow: false},
+				{at: 5 * time.Minute, fingerprint: "f", allow: true, dropped: 1},
+				{at: 5 * time.Minute, fingerprint: "g", allow: true},
+				{at: 5 * time.Minute, fingerprint: "h", allow: false},
+			},
+		},
+		{
+			name:   "duplicates within the window",
+			limits: Limits{DedupWindow: 10 * time.Minute},
+			steps: []step{
+				{at: 0, fingerprint: "a", allow: true},
+				{at: time.Minute, fingerprint: "a", allow: false},
+				{at: 2 * time.Minute, fingerprint: "b", allow: true},
+				{at: 9 * time.Minute, fingerprint: "a", allow: false},
+				{at: 10 * time.Minute, fingerprint: "a", allow: true, repeated: 2},
+				{at: 11 * time.Minute, fingerprint: "a", allow: false},
+			},
+		},
+		{
+			name:   "duplicates don't use tokens",
+			limits: Limits{Rate: 1, Per: time.Hour, DedupWindow: time.Hour},
+			steps: []step{
+				{at: 0, fingerprint: "a", allow: true},
+				{at: time.Minute, fingerprint: "a", allow: false},
+				{at: time.Hour, fingerprint: "a", allow: true, repeated: 1},
+			},
+		},
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+			for i, s := range tt.steps {
+				d, err := Check("ntfy.sh/topic", s.fingerprint, tt.limits, start.Add(s.at))
+				if err != nil {
+					t.Fatalf("step %d: %s", i, err)
+				}
+				if d.Allow != s.allow || d.Dropped != s.dropped || d.Repeated != s.repeated {
+					t.Errorf("step %d: got allow %t, dropped %d, repeated %d, want %t, %d, %d",
+						i, d.Allow, d.Dropped, d.Repeated, s.allow, s.dropped, s.repeated)
+				}
+				if !d.Allow && d.Reason == "" {
+					t.Errorf("step %d: no reason for a held back notification", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSeparatesTargets(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	limits := Limits{Rate: 1, Per: time.Hour, DedupWindow: time.Hour}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, target := range []string{"ntfy.sh/a", "ntfy.sh/b"} {
+		d, err := Check(target, "same", limits, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allow {
+			t.Errorf("first notification to %s was held back: %s", target, d.Reason)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		seen *seen
+		kept bool
+	}{
+		{name: "in the window", seen: &seen{Sent: now.Add(-time.Minute), Expires: now.Add(time.Minute)}, kept: true},
+		{name: "expired", seen: &seen{Sent: now.Add(-time.Hour), Expires: now.Add(-time.Minute)}, kept: false},
+		{name: "expired with repeats", seen: &seen{Sent: now.Add(-time.Hour), Expires: now.Add(-time.Minute), Repeated: 3}, kept: true},
+		{name: "repeats older than a week", seen: &seen{Sent: now.Add(-8 * 24 * time.Hour), Expires: now.Add(-8 * 24 * time.Hour), Repeated: 3}, kept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &file{Seen: map[string]*seen{"key": tt.seen}, Buckets: map[string]*bucket{}}
+			prune(f, now)
+			if _, ok := f.Seen["key"]; ok != tt.kept {
+				t.Errorf("kept %t, want %t", ok, tt.kept)
+			}
+		})
+	}
+
+	buckets := []struct {
+		name   string
+		bucket *bucket
+		kept   bool
+	}{
+		{name: "used today", bucket: &bucket{Updated: now.Add(-time.Hour)}, kept: true},
+		{name: "unused for two days", bucket: &bucket{Updated: now.Add(-48 * time.Hour)}, kept: false},
+		{name: "unused with drops", bucket: &bucket{Updated: now.Add(-48 * time.Hour), Dropped: 2}, kept: true},
+	}
+
+	for _, tt := range buckets {
+		t.Run("bucket "+tt.name, func(t *testing.T) {
+			f := &file{Seen: map[string]*seen{}, Buckets: map[string]*bucket{"target": tt.bucket}}
+			prune(f, now)
+			if _, ok := f.Buckets["target"]; ok != tt.kept {
+				t.Errorf("kept %t, want %t", ok, tt.kept)
+			}
+		})
+	}
+}
+
+func TestDecisionSummary(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		d    Decision
+		want string
+	}{
+		{name: "nothing held back", d: Decision{Allow: true}, want: ""},
+		{name: "repeated", d: Decision{Allow: true, Repeated: 3, Since: since}, want: "Repeated 3 times since 2024-05-01T12:00:00Z"},
+		{name: "dropped", d: Decision{Allow: true, Dropped: 2}, want: "2 notifications dropped by the rate limit"},
+		{
+			name: "both",
+			d:    Decision{Allow: true, Repeated: 1, Since: since, Dropped: 4},
+			want: "Repeated 1 times since 2024-05-01T12:00:00Z\n4 notifications dropped by the rate limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.Summary(); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint("topic", "title", "message") != Fingerprint("topic", "title", "message") {
+		t.Error("identical parts have different fingerprints")
+	}
+	// the separator keeps the parts apart
+	if Fingerprint("ab", "c") == Fingerprint("a", "bc") {
+		t.Error("different parts have the same fingerprint")
+	}
+}
@@ -0,0 +1,60 @@
+// Package retry sends HTTP requests again after transient failures.
+package retry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Policy describes how often and how fast a request is retried.
+type Policy struct {
+	Attempts int
+	// Backoff is the wait before the first retry, doubled for every further one.
+	Backoff time.Duration
+}
+
+var Default = Policy{Attempts: 3, Backoff: time.Second}
+
+// Do sends the request built by newRequest until the response is not a
+// transient failure or all attempts are used. Network errors, 429 and 5xx
+// responses are retried, a Retry-After header is respected. The request is
+// built again for every attempt so its body can be resent.
+func (p Policy) Do(client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if attempt >= p.Attempts || (err == nil && !retryable(resp)) {
+			return resp, err
+		}
+
+		wait := backoff
+		if err != nil {
+			slog.Debug("Request failed, retrying", "url", req.URL.Redacted(), "attempt", attempt, "error", err)
+		} else {
+			slog.Debug("Request failed, retrying", "url", req.URL.Redacted(), "attempt", attempt, "status", resp.Status)
+			if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds * float64(time.Second))
+			}
+			resp.Body.Close()
+		}
+
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+func retryable(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,164 @@
+// Package rules selects the targets of an event with declarative rules from
+// the config file.
+package rules
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/internal/timerange"
+	"github.com/rwxd/notify-me/services/ntfy"
+)
+
+// Rule sends events matching all conditions of Match to the Notify target
+// URLs. Title, Priority and Tags override the event for these targets, the
+// query parameters of a target URL still take precedence.
+type Rule struct {
+	Name     string   `mapstructure:"name"`
+	Match    Match    `mapstructure:"match"`
+	Notify   []string `mapstructure:"notify"`
+	Title    string   `mapstructure:"title"`
+	Priority string   `mapstructure:"priority"`
+	Tags     []string `mapstructure:"tags"`
+	// Stop ends the evaluation if the rule matched.
+	Stop bool `mapstructure:"stop"`
+}
+
+// Match holds the conditions of a rule, empty conditions match everything.
+type Match struct {
+	// Status is success or failure, events without a command never match.
+	Status    string `mapstructure:"status"`
+	ExitCodes []int  `mapstructure:"exit_codes"`
+	// Command is a glob matched against the base name of the program.
+	Command string `mapstructure:"command"`
+	// Tags must all be tags of the event.
+	Tags []string `mapstructure:"tags"`
+	// Host is a glob matched against the hostname.
+	Host string `mapstructure:"host"`
+	// Time is a range of the local time of day like "22:00-06:00".
+	Time string `mapstructure:"time"`
+}
+
+// Selection is a target URL with the overrides of the rule that selected it.
+type Selection struct {
+	Rule  string
+	Url   string
+	Event *notify.Event
+}
+
+// Validate checks the rules for errors, so they are found before the
+// wrapped command runs.
+func Validate(rules []Rule) error {
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if len(r.Notify) == 0 {
+			return fmt.Errorf("rule %s: notify must not be empty", name)
+		}
+		if r.Match.Status != "" && r.Match.Status != "success" && r.Match.Status != "failure" {
+			return fmt.Errorf("rule %s: status must be success or failure", name)
+		}
+		for _, pattern := range []string{r.Match.Command, r.Match.Host} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid pattern %q", name, pattern)
+			}
+		}
+		if r.Match.Time != "" {
+			if _, err := timerange.Parse(r.Match.Time); err != nil {
+				return fmt.Errorf("rule %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Select evaluates the rules in order and returns the targets of all
+// matching rules, until a matching rule with Stop. A target selected by
+// several rules is only returned for the first one.
+func Select(rules []Rule, e *notify.Event) []Selection {
+	var selections []Selection
+	seen := map[string]bool{}
+	for i, r := range rules {
+		if !r.Matches(e) {
+			continue
+		}
+
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, u := range r.Notify {
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+			selections = append(selections, Selection{Rule: name, Url: u, Event: r.apply(e)})
+		}
+
+		if r.Stop {
+			break
+		}
+	}
+
+	return selections
+}
+
+// Matches reports whether the event fulfills all conditions of the rule.
+func (r *Rule) Matches(e *notify.Event) bool {
+	m := r.Match
+
+	if m.Status != "" && m.Status != e.Status() {
+		return false
+	}
+	if len(m.ExitCodes) > 0 && (e.Result == nil || !slices.Contains(m.ExitCodes, e.Result.ExitCode)) {
+		return false
+	}
+	if m.Command != "" {
+		if e.Result == nil {
+			return false
+		}
+		if ok, _ := path.Match(m.Command, filepath.Base(e.Result.Program)); !ok {
+			return false
+		}
+	}
+	for _, tag := range m.Tags {
+		if !slices.Contains(e.Tags, tag) {
+			return false
+		}
+	}
+	if m.Host != "" {
+		if ok, _ := path.Match(m.Host, e.Host); !ok {
+			return false
+		}
+	}
+	if m.Time != "" {
+		if r, err := timerange.Parse(m.Time); err != nil || !r.Contains(e.Time) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply returns a copy of the event with the overrides of the rule.
+func (r *Rule) apply(e *notify.Event) *notify.Event {
+	event := *e
+	if r.Title != "" {
+		event.Title = r.Title
+	}
+	if r.Priority != "" {
+		event.Priority = ntfy.Priority(r.Priority)
+	}
+	if len(r.Tags) > 0 {
+		event.Tags = r.Tags
+	}
+
+	return &event
+}
@@ -0,0 +1,98 @@
+// Package schedule runs jobs by cron expressions, for hosts and containers
+// without cron.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// parser accepts cron expressions with optional seconds as first field and
+// descriptors like @daily or @every 5m.
+var parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule is when a job runs.
+type Schedule struct {
+	cron     cron.Schedule
+	location *time.Location
+	// Jitter delays every run by a random duration up to it, so jobs of
+	// many hosts don't start at the same second.
+	Jitter time.Duration
+}
+
+// Parse parses the cron expression, the times are in the timezone, or the
+// local one if it is empty.
+func Parse(spec, timezone string, jitter time.Duration) (*Schedule, error) {
+	// LoadLocation returns UTC for an empty name
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	s, err := parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	return &Schedule{cron: s, location: location, Jitter: jitter}, nil
+}
+
+// Next is the next scheduled time after t, without jitter.
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.cron.Next(t.In(s.location))
+}
+
+// Missed reports whether a run was scheduled between the last run and now.
+func (s *Schedule) Missed(last, now time.Time) bool {
+	return !last.IsZero() && !s.Next(last).After(now)
+}
+
+func (s *Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+
+	return rand.N(s.Jitter)
+}
+
+// Loop calls run at the scheduled times until the context is done. Runs
+// don't overlap, times passing while run is busy are skipped. With catchUp
+// a run missed since last, e.g. while the host was down, runs right away.
+func (s *Schedule) Loop(ctx context.Context, last time.Time, catchUp bool, run func(scheduled time.Time)) {
+	if catchUp && s.Missed(last, time.Now()) {
+		slog.Debug("Catching up missed run", "last", last)
+		run(s.Next(last))
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	for {
+		next := s.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		at := next.Add(s.jitter())
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run(next)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec     string
+		timezone string
+		wantErr  bool
+	}{
+		{spec: "0 3 * * *"},
+		{spec: "*/30 * * * * *"},
+		{spec: "@daily"},
+		{spec: "@every 5m"},
+		{spec: "0 3 * * *", timezone: "Europe/Berlin"},
+		{spec: "0 3 * * *", timezone: "UTC"},
+		{spec: "0 3 * *", wantErr: true},
+		{spec: "61 * * * *", wantErr: true},
+		{spec: "@sometimes", wantErr: true},
+		{spec: "0 3 * * *", timezone: "Mars/Olympus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.timezone, func(t *testing.T) {
+			_, err := Parse(tt.spec, tt.timezone, 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q, %q) error = %v, want error %t", tt.spec, tt.timezone, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		timezone string
+		want     string
+	}{
+		// an empty timezone is the local one, not UTC
+		{timezone: "", want: time.Local.String()},
+		{timezone: "Local", want: time.Local.String()},
+		{timezone: "UTC", want: "UTC"},
+		{timezone: "Europe/Berlin", want: "Europe/Berlin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			s, err := Parse("@daily", tt.timezone, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.location.String() != tt.want {
+				t.Errorf("location = %s, want %s", s.location, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		from     time.Time
+		want     time.Time
+	}{
+		{
+			name:     "daily in UTC",
+			spec:     "0 3 * * *",
+			timezone: "UTC",
+			from:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "daily in summer time",
+			spec:     "0 3 * * *",
+			timezone: "Europe/Berlin",
+			from:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 2, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "daily in winter time",
+			spec:     "0 3 * * *",
+			timezone: "Europe/Berlin",
+			from:     time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 1, 16, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "with seconds",
+			spec:     "*/30 * * * * *",
+			timezone: "UTC",
+			from:     time.Date(2024, 5, 1, 12, 0, 10, 0, time.UTC),
+			want:     time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC),
+		},
+		{
+			name:     "every",
+			spec:     "@every 5m",
+			timezone: "UTC",
+			from:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekdays",
+			spec:     "0 9 * * 1-5",
+			timezone: "UTC",
+			// a Saturday
+			from: time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.timezone, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMissed(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2024, 5, d, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		spec string
+		last time.Time
+		now  time.Time
+		want bool
+	}{
+		{name: "never ran", spec: "0 3 * * *", last: time.Time{}, now: day(2, 12, 0), want: false},
+		{name: "ran at the last time", spec: "0 3 * * *", last: day(2, 3, 0), now: day(2, 12, 0), want: false},
+		{name: "missed one run", spec: "0 3 * * *", last: day(1, 3, 0), now: day(2, 12, 0), want: true},
+		{name: "missed several runs", spec: "0 3 * * *", last: day(1, 3, 0), now: day(5, 12, 0), want: true},
+		{name: "next run is now", spec: "0 3 * * *", last: day(1, 3, 0), now: day(2, 3, 0), want: true},
+		{name: "next run is in the future", spec: "0 3 * * *", last: day(1, 3, 0), now: day(2, 2, 59), want: false},
+		{name: "every", spec: "@every 10m", last: day(1, 12, 0), now: day(1, 12, 9), want: false},
+		{name: "every missed", spec: "@every 10m", last: day(1, 12, 0), now: day(1, 12, 11), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, "UTC", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Missed(tt.last, tt.now); got != tt.want {
+				t.Errorf("Missed(%s, %s) = %t, want %t", tt.last, tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopCatchUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		last    time.Time
+		catchUp bool
+		want    bool
+	}{
+		{name: "missed run is caught up", last: time.Now().Add(-2 * time.Hour), catchUp: true, want: true},
+		{name: "missed run without catch up", last: time.Now().Add(-2 * time.Hour), catchUp: false, want: false},
+		{name: "nothing missed", last: time.Now().Add(-time.Minute), catchUp: true, want: false},
+		{name: "never ran", last: time.Time{}, catchUp: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse("@every 1h", "UTC", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			var runs []time.Time
+			s.Loop(ctx, tt.last, tt.catchUp, func(scheduled time.Time) {
+				runs = append(runs, scheduled)
+				cancel()
+			})
+
+			if caught := len(runs) > 0; caught != tt.want {
+				t.Fatalf("caught up %t, want %t", caught, tt.want)
+			}
+			if tt.want && !runs[0].Equal(s.Next(tt.last)) {
+				t.Errorf("caught up run scheduled at %s, want %s", runs[0], s.Next(tt.last))
+			}
+		})
+	}
+}
+
+func TestLoopRuns(t *testing.T) {
+	s, err := Parse("* * * * * *", "UTC", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var runs []time.Time
+	s.Loop(ctx, time.Time{}, false, func(scheduled time.Time) {
+		runs = append(runs, scheduled)
+		if len(runs) == 2 {
+			cancel()
+		}
+	})
+
+	if len(runs) != 2 {
+		t.Fatalf("ran %d times, want 2", len(runs))
+	}
+	if d := runs[1].Sub(runs[0]); d != time.Second {
+		t.Errorf("runs %s apart, want 1s", d)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	s, err := Parse("@daily", "UTC", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 100 {
+		if j := s.jitter(); j < 0 || j >= time.Minute {
+			t.Fatalf("jitter %s out of [0, 1m)", j)
+		}
+	}
+
+	s.Jitter = 0
+	if j := s.jitter(); j != 0 {
+		t.Errorf("jitter %s without jitter", j)
+	}
+}
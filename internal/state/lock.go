@@ -0,0 +1,72 @@
+package state
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+// Lock is a named lock of a job, held by one invocation on the host. The
+// lock file holds the PID of the process the holder runs.
+type Lock struct {
+	flock *flock.Flock
+}
+
+func NewLock(name string) (*Lock, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "locks")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &Lock{flock: flock.New(filepath.Join(dir, unsafeChars.ReplaceAllString(name, "_")+".lock"))}, nil
+}
+
+// TryLock takes the lock if it is free.
+func (l *Lock) TryLock() (bool, error) {
+	return l.flock.TryLock()
+}
+
+// LockWithin waits up to timeout for the lock.
+func (l *Lock) LockWithin(timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ok, err := l.flock.TryLockContext(ctx, 500*time.Millisecond)
+	if err != nil && ctx.Err() != nil {
+		return false, nil
+	}
+
+	return ok, err
+}
+
+// SetPID records the PID of the process run by the holder.
+func (l *Lock) SetPID(pid int) error {
+	return os.WriteFile(l.flock.Path(), []byte(strconv.Itoa(pid)+"\n"), 0o600)
+}
+
+// PID is the process run by the holder, 0 if it is unknown.
+func (l *Lock) PID() int {
+	data, err := os.ReadFile(l.flock.Path())
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	return pid
+}
+
+// Unlock releases the lock, the lock file is kept.
+func (l *Lock) Unlock() error {
+	// a stale PID must not be killed by the next holder
+	os.Truncate(l.flock.Path(), 0)
+	return l.flock.Unlock()
+}
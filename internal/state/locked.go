@@ -0,0 +1,48 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/flock"
+)
+
+// Update reads the state file name into v, calls fn to change it and writes
+// it back. A lock file makes concurrent invocations wait for each other.
+func Update(name string, v any, fn func() error) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	// the name may be in a subdirectory, like jobs/ for the job states
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700); err != nil {
+		return err
+	}
+
+	lock := flock.New(filepath.Join(dir, name+".lock"))
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	p := filepath.Join(dir, name+".json")
+	data, err := os.ReadFile(p)
+	if err == nil {
+		// a broken file is replaced, the state is only an optimization
+		_ = json.Unmarshal(data, v)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	if data, err = json.Marshal(v); err != nil {
+		return err
+	}
+
+	return writeFile(p, data)
+}
@@ -0,0 +1,175 @@
+// Package state stores the outcome of wrapped jobs between runs, so
+// notifications can depend on earlier runs.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Dir is the state directory, $XDG_STATE_HOME/notify-me or
+// ~/.local/state/notify-me.
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "notify-me"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".local", "state", "notify-me"), nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Job is the state of a job across runs.
+type Job struct {
+	ID                  string    `json:"id"`
+	LastStatus          string    `json:"last_status"`
+	LastRun             time.Time `json:"last_run"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	FirstFailure        time.Time `json:"first_failure,omitempty"`
+	LastNotified        time.Time `json:"last_notified,omitempty"`
+}
+
+// jobName is the state file of the job for Update, without extension.
+func jobName(id string) string {
+	return filepath.Join("jobs", unsafeChars.ReplaceAllString(id, "_"))
+}
+
+// Load reads the state of the job, a job without state starts empty. It's
+// for reading only, changes go through RecordRun.
+func Load(id string) (*Job, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, jobName(id)+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Job{ID: id}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	j := &Job{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, err
+	}
+	j.ID = id
+
+	return j, nil
+}
+
+// RecordRun records the run in the state of the job and decides whether it
+// notifies. The state file is locked while it changes, so runs of the same
+// job at the same time don't overwrite each other.
+func RecordRun(id string, failed bool, now time.Time, p Policy) (*Job, Outcome, error) {
+	j := &Job{}
+	var o Outcome
+	err := Update(jobName(id), j, func() error {
+		j.ID = id
+		o = j.Record(failed, now, p)
+		return nil
+	})
+
+	return j, o, err
+}
+
+// writeFile replaces the file at p with data by renaming a temporary file,
+// so a crash never leaves a truncated state file.
+func writeFile(p string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+// Policy decides which runs of a job notify. The zero policy notifies on
+// every run.
+type Policy struct {
+	// OnChange notifies only when the job starts failing or recovers.
+	OnChange bool
+	// AfterFailures notifies only from this many failures in a row on.
+	AfterFailures int
+	// RenotifyEvery repeats the notification of a failing job after this
+	// long, zero never repeats it.
+	RenotifyEvery time.Duration
+}
+
+func (p Policy) Enabled() bool {
+	return p.OnChange || p.AfterFailures > 0 || p.RenotifyEvery > 0
+}
+
+// Outcome is the decision for a run.
+type Outcome struct {
+	Notify bool
+	// Recovered is a success after a failure that was notified.
+	Recovered bool
+	// Failures is the number of failures in a row, before the run for
+	// recoveries.
+	Failures     int
+	FirstFailure time.Time
+}
+
+// Record adds the run to the job and decides whether it notifies.
+func (j *Job) Record(failed bool, now time.Time, p Policy) Outcome {
+	threshold := max(p.AfterFailures, 1)
+	// a job was alerting if its failures reached the threshold
+	wasAlerting := j.ConsecutiveFailures >= threshold
+
+	var o Outcome
+	if failed {
+		if j.ConsecutiveFailures == 0 {
+			j.FirstFailure = now
+		}
+		j.ConsecutiveFailures++
+		j.LastStatus = "failure"
+		o = Outcome{Failures: j.ConsecutiveFailures, FirstFailure: j.FirstFailure}
+
+		switch {
+		case !p.Enabled():
+			o.Notify = true
+		case j.ConsecutiveFailures < threshold:
+		case j.ConsecutiveFailures == threshold:
+			o.Notify = true
+		case p.RenotifyEvery > 0:
+			o.Notify = now.Sub(j.LastNotified) >= p.RenotifyEvery
+		}
+	} else {
+		o = Outcome{Failures: j.ConsecutiveFailures, FirstFailure: j.FirstFailure}
+		j.ConsecutiveFailures = 0
+		j.FirstFailure = time.Time{}
+		j.LastStatus = "success"
+
+		if !p.Enabled() {
+			o.Notify = true
+		} else if wasAlerting {
+			o.Notify, o.Recovered = true, true
+		}
+	}
+
+	j.LastRun = now
+	if o.Notify {
+		j.LastNotified = now
+	}
+
+	return o
+}
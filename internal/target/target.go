@@ -0,0 +1,197 @@
+// Package target parses notification targets from URLs, so one line like
+// "ntfy://token@ntfy.example/topic?priority=high" describes a backend and
+// its config.
+package target
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/notify"
+	"github.com/rwxd/notify-me/services/ntfy"
+)
+
+// Sender delivers an event to a backend.
+type Sender interface {
+	Send(e *notify.Event) error
+}
+
+// parsers build the sender for a scheme from the target URL.
+var parsers = map[string]func(u *url.URL) (Sender, error){}
+
+// secretHosts are the schemes with a token as host, String doesn't show it.
+var secretHosts = map[string]bool{}
+
+func register(parse func(u *url.URL) (Sender, error), schemes ...string) {
+	for _, s := range schemes {
+		parsers[s] = parse
+	}
+}
+
+func registerSecretHost(parse func(u *url.URL) (Sender, error), schemes ...string) {
+	register(parse, schemes...)
+	for _, s := range schemes {
+		secretHosts[s] = true
+	}
+}
+
+// Schemes returns the supported URL schemes, sorted.
+func Schemes() []string {
+	schemes := make([]string, 0, len(parsers))
+	for s := range parsers {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// Target is a parsed target URL. The query parameters title, priority and
+// tags override the event for this target, other parameters are backend
+// specific.
+type Target struct {
+	url      *url.URL
+	title    string
+	priority string
+	tags     []string
+	sender   Sender
+}
+
+func Parse(raw string) (*Target, error) {
+	u, err := url.Parse(telegramToken(raw))
+	if err != nil {
+		// the error quotes the url, or for an invalid port its tokens
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) && strings.HasPrefix(urlErr.Err.Error(), "invalid port") {
+			return nil, errors.New("invalid target url: invalid port after host")
+		} else if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("invalid target url: %w", urlErr.Err)
+		}
+		return nil, fmt.Errorf("invalid target url: %w", err)
+	}
+
+	parse, ok := parsers[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("unknown target scheme %q, supported: %s", u.Scheme, strings.Join(Schemes(), ", "))
+	}
+
+	sender, err := parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s target: %w", u.Scheme, err)
+	}
+
+	t := &Target{
+		url:      u,
+		title:    u.Query().Get("title"),
+		priority: u.Query().Get("priority"),
+		sender:   sender,
+	}
+	if tags := u.Query().Get("tags"); tags != "" {
+		t.tags = strings.Split(tags, ",")
+	}
+
+	return t, nil
+}
+
+// Send delivers the event with the overrides of the target applied.
+func (t *Target) Send(e *notify.Event) error {
+	event := *e
+	if t.title != "" {
+		event.Title = t.title
+	}
+	if t.priority != "" {
+		event.Priority = ntfy.Priority(t.priority)
+	}
+	if t.tags != nil {
+		event.Tags = t.tags
+	}
+
+	err := t.sender.Send(&event)
+	// URLs of webhooks and bots contain their tokens
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s failed: %w", urlErr.Op, urlErr.Err)
+	}
+
+	return err
+}
+
+// String is the scheme and host of the target, without credentials or tokens.
+// For schemes with a token as host only the scheme is shown.
+func (t *Target) String() string {
+	if secretHosts[strings.ToLower(t.url.Scheme)] {
+		return t.url.Scheme + "://***"
+	}
+
+	return t.url.Scheme + "://" + t.url.Host
+}
+
+// telegramToken moves the bot token of tgram://botToken/chatID into the user
+// info in front of the API host. Tokens like 123456:AAH… don't parse as host,
+// the part after the colon is read as port.
+func telegramToken(raw string) string {
+	const scheme = "tgram://"
+	if len(raw) < len(scheme) || !strings.EqualFold(raw[:len(scheme)], scheme) {
+		return raw
+	}
+
+	rest := raw[len(scheme):]
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	if strings.Contains(rest[:end], "@") {
+		return raw
+	}
+
+	return raw[:len(scheme)] + rest[:end] + "@api.telegram.org" + rest[end:]
+}
+
+// httpScheme returns https for the secure variant of a scheme, ntfys://
+// for ntfy:// for example.
+func httpScheme(u *url.URL, secure string) string {
+	if strings.EqualFold(u.Scheme, secure) {
+		return "https"
+	}
+
+	return "http"
+}
+
+// pathSegments returns the non empty segments of the URL path.
+func pathSegments(u *url.URL) []string {
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+
+	return segments
+}
+
+// body is the message, or for backends that reject blank messages a
+// summary of the run or the title.
+func body(e *notify.Event) string {
+	switch {
+	case strings.TrimSpace(e.Message) != "":
+		return e.Message
+	case e.Result != nil:
+		return e.Result.Summary()
+	case strings.TrimSpace(e.Title) != "":
+		return e.Title
+	}
+
+	return "(no message)"
+}
+
+// text is the message with the title in front, for backends without titles.
+func text(e *notify.Event) string {
+	if e.Title == "" {
+		return e.Message
+	}
+
+	return e.Title + "\n\n" + e.Message
+}
@@ -0,0 +1,54 @@
+// Package timerange handles ranges of the time of day like "22:00-07:00".
+package timerange
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Range is a range of the time of day, in minutes since midnight. A range
+// with an end before the start spans midnight, the end is exclusive.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Parse parses a range in the format "15:04-15:04".
+func Parse(s string) (Range, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Range{}, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+	}
+
+	return Range{Start: start.Hour()*60 + start.Minute(), End: end.Hour()*60 + end.Minute()}, nil
+}
+
+// Contains reports whether the time of day of t, in its location, is in the range.
+func (r Range) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if r.Start <= r.End {
+		return minute >= r.Start && minute < r.End
+	}
+
+	return minute >= r.Start || minute < r.End
+}
+
+// NextEnd returns the next end of the range after t, in the location of t.
+func (r Range) NextEnd(t time.Time) time.Time {
+	end := time.Date(t.Year(), t.Month(), t.Day(), r.End/60, r.End%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end
+}
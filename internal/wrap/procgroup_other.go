@@ -0,0 +1,26 @@
+//go:build !linux && !darwin
+
+package wrap
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(command *exec.Cmd) {}
+
+func signalGroup(pid int, sig os.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if sig == os.Kill {
+		return p.Kill()
+	}
+
+	return p.Signal(sig)
+}
+
+func groupExists(pid int) bool {
+	return processExists(pid)
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin
+
+package wrap
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup signals the process group led by pid, or only the process if
+// it doesn't lead a group.
+func signalGroup(pid int, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("unsupported signal")
+	}
+
+	err := syscall.Kill(-pid, s)
+	if errors.Is(err, syscall.ESRCH) {
+		err = syscall.Kill(pid, s)
+	}
+
+	return err
+}
+
+func groupExists(pid int) bool {
+	return syscall.Kill(-pid, 0) == nil || syscall.Kill(pid, 0) == nil
+}
@@ -0,0 +1,16 @@
+package wrap
+
+import (
+	"os"
+	"syscall"
+)
+
+func maxRSS(state *os.ProcessState) (int64, bool) {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0, false
+	}
+
+	// darwin reports bytes
+	return rusage.Maxrss, true
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package wrap
+
+import "os"
+
+func maxRSS(state *os.ProcessState) (int64, bool) {
+	return 0, false
+}
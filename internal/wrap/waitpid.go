@@ -0,0 +1,47 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// ErrNoProcess is returned for processes that don't exist.
+var ErrNoProcess = errors.New("no such process")
+
+// WaitPID waits until the process pid, which doesn't need to be a child,
+// ended. The exit status of processes that are no child is unknown, so the
+// result never failed. The command and the start of the process are taken
+// from /proc where available, otherwise the command is the PID and the
+// duration is counted from the call.
+func WaitPID(pid int, poll time.Duration) (*Result, error) {
+	if !processExists(pid) {
+		return nil, fmt.Errorf("process %d: %w", pid, ErrNoProcess)
+	}
+
+	host, _ := os.Hostname()
+	result := &Result{Program: fmt.Sprintf("pid %d", pid), Host: host, Started: time.Now()}
+	if args, started, ok := processInfo(pid); ok {
+		result.Program, result.Args, result.Started = args[0], args[1:], started
+	}
+
+	slog.Debug("Waiting for process", "pid", pid, "command", result.Command())
+	if err := waitExit(pid, poll); err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(result.Started)
+
+	return result, nil
+}
+
+// pollExit checks every interval whether the process still exists.
+func pollExit(pid int, interval time.Duration) error {
+	for processExists(pid) {
+		time.Sleep(interval)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package wrap
+
+import (
+	"syscall"
+	"time"
+)
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func waitExit(pid int, poll time.Duration) error {
+	return pollExit(pid, poll)
+}
+
+// processInfo is not available without /proc.
+func processInfo(pid int) ([]string, time.Time, bool) {
+	return nil, time.Time{}, false
+}
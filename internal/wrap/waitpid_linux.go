@@ -0,0 +1,87 @@
+package wrap
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func processExists(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
+// waitExit waits with a pidfd, kernels before 5.3 fall back to polling.
+func waitExit(pid int, poll time.Duration) error {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return pollExit(pid, poll)
+	}
+	defer unix.Close(fd)
+
+	for {
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		if _, err := unix.Poll(fds, -1); err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// processInfo reads the command line and the start of the process from /proc.
+func processInfo(pid int) ([]string, time.Time, bool) {
+	cmdline, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+	if err != nil || len(cmdline) == 0 {
+		return nil, time.Time{}, false
+	}
+	args := strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+
+	started, err := processStart(pid)
+	if err != nil {
+		started = time.Now()
+	}
+
+	return args, started, true
+}
+
+// processStart is the boot time plus the start time of the process in clock
+// ticks since boot, field 22 of /proc/<pid>/stat.
+func processStart(pid int) (time.Time, error) {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	// the command in field 2 may contain spaces, the fields after it don't
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, os.ErrInvalid
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	procStat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(procStat), "\n") {
+		if btime, ok := strings.CutPrefix(line, "btime "); ok {
+			boot, err := strconv.ParseInt(btime, 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			// USER_HZ is 100 on all architectures Go supports
+			return time.Unix(boot, 0).Add(time.Duration(ticks) * time.Second / 100), nil
+		}
+	}
+
+	return time.Time{}, os.ErrInvalid
+}
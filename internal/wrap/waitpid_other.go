@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package wrap
+
+import (
+	"errors"
+	"time"
+)
+
+func processExists(pid int) bool {
+	return true
+}
+
+func waitExit(pid int, poll time.Duration) error {
+	return errors.New("waiting for processes is not supported on this platform")
+}
+
+func processInfo(pid int) ([]string, time.Time, bool) {
+	return nil, time.Time{}, false
+}
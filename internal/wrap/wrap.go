@@ -0,0 +1,269 @@
+// Package wrap runs a wrapped command and records what the notification
+// backends report about it.
+package wrap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Result describes a finished run of a wrapped command.
+type Result struct {
+	Program  string
+	Args     []string
+	Output   string
+	Err      error
+	ExitCode int
+	Started  time.Time
+	Duration time.Duration
+	Host     string
+	State    *os.ProcessState
+}
+
+// Run executes program with args and captures stdout and stderr combined.
+func Run(program string, args ...string) *Result {
+	return RunStarted(nil, program, args...)
+}
+
+// RunStarted is Run, calling started with the process once it started. The
+// command runs in its own process group, so StopGroup also reaches the
+// processes it started. Interrupts of notify-me are passed on to the group.
+func RunStarted(started func(*os.Process), program string, args ...string) *Result {
+	command := exec.Command(program, args...)
+
+	var output bytes.Buffer
+	command.Stdout = &output
+	command.Stderr = &output
+	if started != nil {
+		setProcessGroup(command)
+	}
+
+	slog.Debug("Running command", "program", program, "args", args)
+	startedAt := time.Now()
+	err := command.Start()
+	if err == nil {
+		if started != nil {
+			stop := forwardSignals(command.Process.Pid)
+			started(command.Process)
+			err = command.Wait()
+			stop()
+		} else {
+			err = command.Wait()
+		}
+	}
+
+	host, _ := os.Hostname()
+
+	result := &Result{
+		Program:  program,
+		Args:     args,
+		Output:   output.String(),
+		Err:      err,
+		Started:  startedAt,
+		Duration: time.Since(startedAt),
+		Host:     host,
+		State:    command.ProcessState,
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		result.ExitCode = -1
+	}
+
+	if err != nil {
+		slog.Debug("Command failed", "error", err)
+	} else {
+		slog.Debug("Command succeeded")
+	}
+
+	return result
+}
+
+// forwardSignals passes interrupts to the process group of pid, which no
+// longer gets them from the terminal, until stop is called.
+func forwardSignals(pid int) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				slog.Debug("Passing on signal", "signal", sig, "pid", pid)
+				signalGroup(pid, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// StopGroup stops the process group led by pid, a process started by
+// RunStarted. It is terminated first and killed if it is still running
+// after grace.
+func StopGroup(pid int, grace time.Duration) error {
+	if err := signalGroup(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if !groupExists(pid) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	slog.Debug("Process group still running, killing it", "pid", pid)
+	if err := signalGroup(pid, os.Kill); err != nil && groupExists(pid) {
+		return err
+	}
+
+	return nil
+}
+
+// Failed reports whether the command could not be started or exited non-zero.
+func (r *Result) Failed() bool {
+	return r.Err != nil
+}
+
+// Message builds the notification body the wrap commands send: the custom
+// message, followed by the command output unless onlyMessage is set, and the
+// error if the command failed.
+func (r *Result) Message(message string, onlyMessage bool) string {
+	if onlyMessage {
+	} else if message != "" {
+		message += "\n" + r.Output
+	} else {
+		message = r.Output
+	}
+
+	if r.Err != nil {
+		if message == "" {
+			message = r.Err.Error()
+		} else {
+			message += "\n" + r.Err.Error()
+		}
+	}
+
+	return message
+}
+
+// MessageWithin builds the notification body like Message, but keeps it under
+// max bytes by only sending the end of the output. The custom message and the
+// error are kept as long as they fit on their own.
+func (r *Result) MessageWithin(message string, onlyMessage bool, max int) string {
+	full := r.Message(message, onlyMessage)
+	if max <= 0 || len(full) <= max {
+		return full
+	}
+
+	without := r.Message(message, true)
+	if onlyMessage || len(without)+1 >= max {
+		return Tail(full, max)
+	}
+
+	output := Tail(r.Output, max-len(without)-1)
+	if message == "" && r.Err == nil {
+		return output
+	} else if message == "" {
+		return output + "\n" + r.Err.Error()
+	} else if r.Err == nil {
+		return message + "\n" + output
+	}
+
+	return message + "\n" + output + "\n" + r.Err.Error()
+}
+
+// Summary is a one line description of the run, for backends that reject
+// empty messages.
+func (r *Result) Summary() string {
+	if r.Failed() {
+		return r.Command() + " failed on " + r.Host
+	}
+
+	return r.Command() + " succeeded on " + r.Host
+}
+
+// Fingerprint is a stable ID of the command on this host, the same for every
+// run of it.
+func (r *Result) Fingerprint() string {
+	sum := sha256.Sum256([]byte(r.Host + "\x00" + strings.Join(append([]string{r.Program}, r.Args...), "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MaxRSS returns the peak resident memory of the command in bytes, if the
+// platform reports it.
+func (r *Result) MaxRSS() (int64, bool) {
+	if r.State == nil {
+		return 0, false
+	}
+
+	return maxRSS(r.State)
+}
+
+// Command returns the wrapped command line.
+func (r *Result) Command() string {
+	return strings.Join(append([]string{r.Program}, r.Args...), " ")
+}
+
+const ellipsis = "…"
+
+// Tail returns at most max bytes from the end of s, cut at a rune boundary
+// and marked with a leading ellipsis if anything was dropped.
+func Tail(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+
+	marker := ellipsis
+	if max <= len(marker) {
+		marker = ""
+	}
+
+	s = s[len(s)-max+len(marker):]
+	for len(s) > 0 && !utf8.RuneStart(s[0]) {
+		s = s[1:]
+	}
+
+	return marker + s
+}
+
+// Head returns at most max bytes from the start of s, cut at a rune
+// boundary and marked with a trailing ellipsis if anything was dropped. It's
+// for titles, where the start matters more than the end.
+func Head(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+
+	marker := ellipsis
+	if max <= len(marker) {
+		marker = ""
+	}
+
+	n := max - len(marker)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n] + marker
+}
@@ -0,0 +1,156 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Limits of a webhook message in characters.
+const (
+	ContentLimit     = 2000
+	DescriptionLimit = 4096
+	TitleLimit       = 256
+)
+
+var (
+	ColorSuccess = 0x2ecc71
+	ColorFailure = 0xe74c3c
+)
+
+type Embed struct {
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Url         string     `json:"url,omitempty"`
+	Color       int        `json:"color,omitempty"`
+	Thumbnail   *Thumbnail `json:"thumbnail,omitempty"`
+	Fields      []Field    `json:"fields,omitempty"`
+}
+
+type Thumbnail struct {
+	Url string `json:"url"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// File is uploaded as attachment of the message.
+type File struct {
+	Name string
+	Data []byte
+}
+
+type Message struct {
+	Content   string  `json:"content,omitempty"`
+	Username  string  `json:"username,omitempty"`
+	AvatarUrl string  `json:"avatar_url,omitempty"`
+	Embeds    []Embed `json:"embeds,omitempty"`
+	File      *File   `json:"-"`
+}
+
+func SendMessage(m *Message, webhook string) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	body := bytes.NewBuffer(payload)
+	contentType := "application/json"
+	if m.File != nil {
+		body = &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		if err := w.WriteField("payload_json", string(payload)); err != nil {
+			return err
+		}
+		part, err := w.CreateFormFile("files[0]", m.File.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(m.File.Data); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		contentType = w.FormDataContentType()
+	}
+
+	slog.Debug("Sending request to discord webhook", "body", string(payload))
+	// long messages are split into a burst of posts, the webhook rate limit
+	// answers with 429 and Retry-After
+	data := body.Bytes()
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", webhook, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	if err != nil {
+		// don't leak the webhook token in the URL of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send message to discord: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send message to discord, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+// Chunk splits s into parts of at most size characters, preferring to split
+// after a newline.
+func Chunk(s string, size int) []string {
+	var chunks []string
+	runes := []rune(s)
+	for len(runes) > size {
+		cut := size
+		for i := size - 1; i > size/2; i-- {
+			if runes[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+
+	return chunks
+}
+
+// CodeBlocks splits output into code blocks each fitting in the content of a message.
+func CodeBlocks(output string) []string {
+	const fence = "```\n"
+
+	// a zero width space keeps backticks in the output from closing the block
+	output = strings.ReplaceAll(strings.TrimSuffix(output, "\n"), "```", "`\u200b``")
+
+	var blocks []string
+	for _, chunk := range Chunk(output, ContentLimit-2*len(fence)) {
+		blocks = append(blocks, fence+chunk+"\n```")
+	}
+
+	return blocks
+}
@@ -0,0 +1,323 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Security is how the connection to the server is encrypted.
+type Security string
+
+var (
+	SecurityStartTLS Security = "starttls"
+	SecurityTLS      Security = "tls"
+	SecurityNone     Security = "none"
+)
+
+// AuthMethod is the SMTP authentication mechanism.
+type AuthMethod string
+
+var (
+	AuthPlain AuthMethod = "plain"
+	AuthLogin AuthMethod = "login"
+	AuthNone  AuthMethod = "none"
+)
+
+type Server struct {
+	Host     string
+	Port     int
+	Security Security
+	Auth     AuthMethod
+	Username string
+	Password string
+}
+
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	From        string
+	To          []string
+	Cc          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Send delivers the message to all To and Cc recipients.
+func Send(s *Server, m *Message) error {
+	data, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	var recipients []string
+	for _, r := range append(append([]string{}, m.To...), m.Cc...) {
+		addr, err := mail.ParseAddress(r)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", r, err)
+		}
+		recipients = append(recipients, addr.Address)
+	}
+	if len(recipients) == 0 {
+		return errors.New("no recipients")
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+
+	slog.Debug("Connecting to smtp server", "addr", addr, "security", s.Security)
+	var conn net.Conn
+	if s.Security == SecurityTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.Security == SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	switch s.Auth {
+	case AuthPlain:
+		err = c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host))
+	case AuthLogin:
+		err = c.Auth(&loginAuth{username: s.Username, password: s.Password})
+	}
+	if err != nil {
+		return fmt.Errorf("smtp authentication failed: %w", err)
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, r := range recipients {
+		if err := c.Rcpt(r); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", r, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide.
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// Bytes builds the MIME message: the text, with the HTML as alternative if
+// set, and the attachments.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", m.From)
+	header.Set("To", strings.Join(m.To, ", "))
+	if len(m.Cc) > 0 {
+		header.Set("Cc", strings.Join(m.Cc, ", "))
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID(m.From))
+	header.Set("MIME-Version", "1.0")
+
+	body, bodyHeader, err := m.body()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Attachments) == 0 {
+		writeHeader(&buf, header, bodyHeader)
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	w := multipart.NewWriter(&parts)
+	part, err := w.CreatePart(bodyHeader)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(body)
+
+	for _, a := range m.Attachments {
+		mediaType, params, err := mime.ParseMediaType(a.ContentType)
+		if err != nil {
+			mediaType, params = "application/octet-stream", map[string]string{}
+		}
+		params["name"] = a.Name
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(mediaType, params)},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, a.Data)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	writeHeader(&buf, header, textproto.MIMEHeader{
+		"Content-Type": {"multipart/mixed; boundary=" + w.Boundary()},
+	})
+	buf.Write(parts.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+func (m *Message) body() ([]byte, textproto.MIMEHeader, error) {
+	text := quotedPrintable(m.Text)
+	textHeader := textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	}
+	if m.HTML == "" {
+		return text, textHeader, nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreatePart(textHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	part.Write(text)
+
+	part, err = w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	part.Write(quotedPrintable(m.HTML))
+
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + w.Boundary()}}, nil
+}
+
+func writeHeader(buf *bytes.Buffer, headers ...textproto.MIMEHeader) {
+	for _, header := range headers {
+		for key, values := range header {
+			for _, v := range values {
+				fmt.Fprintf(buf, "%s: %s\r\n", key, v)
+			}
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func quotedPrintable(s string) []byte {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	w.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n")))
+	w.Close()
+
+	return buf.Bytes()
+}
+
+func writeBase64(w interface{ Write([]byte) (int, error) }, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+
+	b := make([]byte, 12)
+	rand.Read(b)
+
+	return fmt.Sprintf("<%x.%d@%s>", b, time.Now().Unix(), domain)
+}
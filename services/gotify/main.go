@@ -0,0 +1,78 @@
+package gotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+type Message struct {
+	Title    string
+	Message  string
+	Priority int
+	Markdown bool
+	Url      string
+}
+
+type messageRequest struct {
+	Title    string         `json:"title,omitempty"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+func SendMessage(m *Message, instance, token string) error {
+	payload := messageRequest{
+		Title:    m.Title,
+		Message:  m.Message,
+		Priority: m.Priority,
+		Extras:   map[string]any{},
+	}
+	if m.Markdown {
+		payload.Extras["client::display"] = map[string]string{"contentType": "text/markdown"}
+	}
+	if m.Url != "" {
+		payload.Extras["client::notification"] = map[string]any{"click": map[string]string{"url": m.Url}}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(instance, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", token)
+
+	slog.Debug("Sending request to gotify", "url", req.URL.String(), "body", string(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send message to gotify, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+func NewMessage(title, message string, priority int, markdown bool, url string) *Message {
+	return &Message{
+		Title:    title,
+		Message:  message,
+		Priority: priority,
+		Markdown: markdown,
+		Url:      url,
+	}
+}
@@ -0,0 +1,114 @@
+package healthchecks
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Signal is the kind of ping sent to a check, appended to the ping URL.
+type Signal string
+
+var (
+	SignalSuccess Signal = ""
+	SignalStart   Signal = "start"
+	SignalFail    Signal = "fail"
+	SignalLog     Signal = "log"
+)
+
+// ExitStatus returns the signal reporting an exit code, 0 is a success and
+// everything else a failure. Healthchecks only takes exit codes from 0 to 255,
+// others like -1 for a command that could not start are sent as fail.
+func ExitStatus(code int) Signal {
+	if code < 0 || code > 255 {
+		return SignalFail
+	}
+
+	return Signal(strconv.Itoa(code))
+}
+
+// Check identifies a check either by its UUID or by the project ping key and
+// the check slug.
+type Check struct {
+	Instance string
+	UUID     string
+	PingKey  string
+	Slug     string
+	// Create asks the instance to create a check for an unknown slug.
+	Create bool
+}
+
+func NewCheck(instance, uuid, pingKey, slug string, create bool) *Check {
+	return &Check{
+		Instance: instance,
+		UUID:     uuid,
+		PingKey:  pingKey,
+		Slug:     slug,
+		Create:   create,
+	}
+}
+
+// URL builds the ping URL for the signal, rid identifies the run and may be empty.
+func (c *Check) URL(signal Signal, rid string) string {
+	u := strings.TrimSuffix(c.Instance, "/") + "/"
+	if c.UUID != "" {
+		u += url.PathEscape(c.UUID)
+	} else {
+		u += url.PathEscape(c.PingKey) + "/" + url.PathEscape(c.Slug)
+	}
+	if signal != SignalSuccess {
+		u += "/" + string(signal)
+	}
+
+	q := url.Values{}
+	if rid != "" {
+		q.Set("rid", rid)
+	}
+	if c.Create && c.UUID == "" {
+		q.Set("create", "1")
+	}
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	return u
+}
+
+// SendPing posts body to the ping URL of the check.
+func SendPing(c *Check, signal Signal, body, rid string) error {
+	req, err := http.NewRequest("POST", c.URL(signal, rid), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	slog.Debug("Sending request to healthchecks", "url", req.URL.String())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send ping to healthchecks, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+// NewRunID returns a random UUID to relate the start and end pings of a run.
+func NewRunID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,88 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Socket is where journald listens for the native protocol.
+const Socket = "/run/systemd/journal/socket"
+
+var fieldName = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_]*$`)
+
+// Entry is a journal entry, Fields are the additional fields besides
+// MESSAGE, PRIORITY and SYSLOG_IDENTIFIER.
+type Entry struct {
+	Message    string
+	Priority   int
+	Identifier string
+	Fields     map[string]string
+}
+
+// Send writes the entry to journald, with the native protocol as one
+// datagram. Field names must be uppercase letters, digits and underscores.
+func Send(socket string, e *Entry) error {
+	data, err := e.Serialize()
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return fmt.Errorf("failed to connect to journald %s: %w", socket, err)
+	}
+	defer conn.Close()
+
+	slog.Debug("Sending journal entry", "socket", socket, "size", len(data))
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("failed to send journal entry: %w", err)
+	}
+
+	return nil
+}
+
+// Serialize encodes the entry in the native protocol.
+func (e *Entry) Serialize() ([]byte, error) {
+	fields := map[string]string{
+		"MESSAGE":  e.Message,
+		"PRIORITY": fmt.Sprint(e.Priority),
+	}
+	if e.Identifier != "" {
+		fields["SYSLOG_IDENTIFIER"] = e.Identifier
+	}
+	for name, value := range e.Fields {
+		if !fieldName.MatchString(name) {
+			return nil, fmt.Errorf("invalid journal field name %q", name)
+		}
+		fields[name] = value
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		value := fields[name]
+		if !strings.Contains(value, "\n") {
+			buf.WriteString(name + "=" + value + "\n")
+			continue
+		}
+
+		// values with newlines are written with their length
+		buf.WriteString(name + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value + "\n")
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,134 @@
+package matrix
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+type MsgType string
+
+var (
+	MsgTypeText   MsgType = "m.text"
+	MsgTypeNotice MsgType = "m.notice"
+)
+
+// Message is the content of an m.room.message event.
+type Message struct {
+	MsgType       MsgType `json:"msgtype"`
+	Body          string  `json:"body"`
+	Format        string  `json:"format,omitempty"`
+	FormattedBody string  `json:"formatted_body,omitempty"`
+}
+
+// NewMessage creates a message, html is sent as formatted body if not empty.
+func NewMessage(body, html string, notice bool) *Message {
+	m := &Message{
+		MsgType: MsgTypeText,
+		Body:    body,
+	}
+	if notice {
+		m.MsgType = MsgTypeNotice
+	}
+	if html != "" {
+		m.Format = "org.matrix.custom.html"
+		m.FormattedBody = html
+	}
+
+	return m
+}
+
+// ResolveRoom returns the room ID for a room alias like #room:example.org,
+// room IDs are returned unchanged.
+func ResolveRoom(homeserver, token, room string) (string, error) {
+	if !strings.HasPrefix(room, "#") {
+		return room, nil
+	}
+
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", clientURL(homeserver, "directory", "room", room), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return "", fmt.Errorf("failed to resolve room alias %s, status: %s, body: %s", room, string(resp.Status), string(body[:n]))
+	}
+
+	var directory struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&directory); err != nil {
+		return "", err
+	} else if directory.RoomID == "" {
+		return "", fmt.Errorf("room alias %s did not resolve to a room", room)
+	}
+
+	slog.Debug("Resolved room alias", "alias", room, "room", directory.RoomID)
+	return directory.RoomID, nil
+}
+
+// SendMessage sends the message to a room. The transaction ID makes retries
+// idempotent, the homeserver ignores a second event with the same ID.
+func SendMessage(m *Message, homeserver, token, roomID, txnID string) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Sending message to matrix", "room", roomID, "txn", txnID, "body", string(body))
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("PUT", clientURL(homeserver, "rooms", roomID, "send", "m.room.message", txnID), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send message to matrix, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+// NewTransactionID returns a random transaction ID for SendMessage.
+func NewTransactionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "notify-me-" + hex.EncodeToString(b)
+}
+
+func clientURL(homeserver string, segments ...string) string {
+	u := strings.TrimSuffix(homeserver, "/") + "/_matrix/client/v3"
+	for _, s := range segments {
+		u += "/" + url.PathEscape(s)
+	}
+
+	return u
+}
@@ -0,0 +1,72 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+const timeout = 30 * time.Second
+
+// Broker is where messages are published to. The URL scheme selects the
+// transport: tcp://, ssl:// or tls://, ws:// or wss://.
+type Broker struct {
+	Url      string
+	Username string
+	Password string
+	ClientID string
+}
+
+func NewBroker(url, username, password, clientID string) *Broker {
+	return &Broker{
+		Url:      url,
+		Username: username,
+		Password: password,
+		ClientID: clientID,
+	}
+}
+
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Publish connects to the broker, publishes the messages in order and
+// disconnects. With QoS 1 and 2 it waits until the broker confirmed them.
+func Publish(b *Broker, messages ...*Message) error {
+	opts := paho.NewClientOptions().
+		AddBroker(b.Url).
+		SetClientID(b.ClientID).
+		SetUsername(b.Username).
+		SetPassword(b.Password).
+		SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}).
+		SetConnectTimeout(timeout).
+		SetAutoReconnect(false).
+		SetCleanSession(true)
+
+	slog.Debug("Connecting to mqtt broker", "url", b.Url, "client_id", b.ClientID)
+	client := paho.NewClient(opts)
+	if token := client.Connect(); !token.WaitTimeout(timeout) {
+		return fmt.Errorf("timeout connecting to mqtt broker %s", b.Url)
+	} else if token.Error() != nil {
+		return fmt.Errorf("failed to connect to mqtt broker %s: %w", b.Url, token.Error())
+	}
+	defer client.Disconnect(250)
+
+	for _, m := range messages {
+		slog.Debug("Publishing mqtt message", "topic", m.Topic, "qos", m.QoS, "retain", m.Retain, "payload", string(m.Payload))
+		token := client.Publish(m.Topic, m.QoS, m.Retain, m.Payload)
+		if !token.WaitTimeout(timeout) {
+			return fmt.Errorf("timeout publishing to %s", m.Topic)
+		} else if token.Error() != nil {
+			return fmt.Errorf("failed to publish to %s: %w", m.Topic, token.Error())
+		}
+	}
+
+	return nil
+}
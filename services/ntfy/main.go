@@ -10,13 +10,40 @@ import (
 type Priority string
 
 var (
-	priorityMin     Priority = "min"
-	priorityLow     Priority = "low"
-	priorityDefault Priority = "default"
-	priorityHigh    Priority = "high"
-	priorityMax     Priority = "max"
+	PriorityMin     Priority = "min"
+	PriorityLow     Priority = "low"
+	PriorityDefault Priority = "default"
+	PriorityHigh    Priority = "high"
+	PriorityMax     Priority = "max"
 )
 
+// Level returns the priority as number from 1 (min) to 5 (max), like ntfy
+// accepts it. Empty and unknown priorities are the default 3.
+func (p Priority) Level() int {
+	switch strings.ToLower(string(p)) {
+	case string(PriorityMin), "1":
+		return 1
+	case string(PriorityLow), "2":
+		return 2
+	case string(PriorityHigh), "4":
+		return 4
+	case string(PriorityMax), "urgent", "5":
+		return 5
+	}
+
+	return 3
+}
+
+// Valid reports whether p is empty or a priority Level knows.
+func (p Priority) Valid() bool {
+	switch strings.ToLower(string(p)) {
+	case "", string(PriorityDefault), "3":
+		return true
+	}
+
+	return p.Level() != 3
+}
+
 type Notification struct {
 	Topic    string
 	Title    string
@@ -0,0 +1,109 @@
+package opsgenie
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Limits of an alert in characters.
+const (
+	MessageLimit     = 130
+	DescriptionLimit = 15000
+)
+
+type Priority string
+
+var (
+	PriorityP1 Priority = "P1"
+	PriorityP2 Priority = "P2"
+	PriorityP3 Priority = "P3"
+	PriorityP4 Priority = "P4"
+	PriorityP5 Priority = "P5"
+)
+
+type Client struct {
+	ApiUrl string
+	ApiKey string
+}
+
+func NewClient(apiUrl, apiKey string) *Client {
+	return &Client{
+		ApiUrl: apiUrl,
+		ApiKey: apiKey,
+	}
+}
+
+// Alert is created or, if an open alert with the same alias exists, counted
+// as another occurrence of it.
+type Alert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    Priority          `json:"priority,omitempty"`
+}
+
+func (c *Client) CreateAlert(a *Alert) error {
+	a.Message = truncate(a.Message, MessageLimit)
+	a.Description = truncate(a.Description, DescriptionLimit)
+
+	slog.Debug("Creating opsgenie alert", "alias", a.Alias)
+	return c.post("/v2/alerts", a)
+}
+
+// CloseAlert closes the open alert with the alias, if there is one.
+func (c *Client) CloseAlert(alias, source, note string) error {
+	slog.Debug("Closing opsgenie alert", "alias", alias)
+	return c.post("/v2/alerts/"+url.PathEscape(alias)+"/close?identifierType=alias", map[string]string{
+		"source": source,
+		"note":   note,
+	})
+}
+
+func (c *Client) post(path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", strings.TrimSuffix(c.ApiUrl, "/")+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "GenieKey "+c.ApiKey)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send request to opsgenie, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	return string(runes[:limit])
+}
@@ -0,0 +1,98 @@
+package pagerduty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// SummaryLimit is the maximum length of the summary in characters.
+const SummaryLimit = 1024
+
+type Action string
+
+var (
+	ActionTrigger     Action = "trigger"
+	ActionAcknowledge Action = "acknowledge"
+	ActionResolve     Action = "resolve"
+)
+
+type Severity string
+
+var (
+	SeverityCritical Severity = "critical"
+	SeverityError    Severity = "error"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+type Payload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      Severity       `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	Group         string         `json:"group,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+}
+
+// Event is an Events API v2 event. Events with the same dedup key belong to
+// the same incident, so a resolve closes the incident of an earlier trigger.
+type Event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction Action   `json:"event_action"`
+	DedupKey    string   `json:"dedup_key,omitempty"`
+	Payload     *Payload `json:"payload,omitempty"`
+	Links       []Link   `json:"links,omitempty"`
+}
+
+// SendEvent sends the event and returns the dedup key of the incident.
+func SendEvent(apiUrl string, e *Event) (string, error) {
+	if e.Payload != nil {
+		if runes := []rune(e.Payload.Summary); len(runes) > SummaryLimit {
+			e.Payload.Summary = string(runes[:SummaryLimit])
+		}
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	slog.Debug("Sending event to pagerduty", "action", e.EventAction, "dedup_key", e.DedupKey)
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", strings.TrimSuffix(apiUrl, "/")+"/v2/enqueue", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status   string   `json:"status"`
+		Message  string   `json:"message"`
+		DedupKey string   `json:"dedup_key"`
+		Errors   []string `json:"errors"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("failed to send event to pagerduty, status: %s, message: %s %s", resp.Status, result.Message, strings.Join(result.Errors, ", "))
+	}
+
+	return result.DedupKey, nil
+}
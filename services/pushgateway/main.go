@@ -0,0 +1,119 @@
+package pushgateway
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Gauge is a single sample pushed as gauge.
+type Gauge struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+// Label is a grouping key label, the order is kept in the URL.
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Client struct {
+	Url      string
+	Username string
+	Password string
+}
+
+func NewClient(url, username, password string) *Client {
+	return &Client{
+		Url:      url,
+		Username: username,
+		Password: password,
+	}
+}
+
+// Push sends the gauges to the group of the job and grouping labels. It uses
+// POST, so metrics of the group that are not pushed keep their last value.
+func (c *Client) Push(job string, grouping []Label, gauges []Gauge) error {
+	body := Format(gauges)
+	u := c.groupURL(job, grouping)
+
+	slog.Debug("Pushing metrics to pushgateway", "url", u, "body", body)
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", u, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to push metrics to pushgateway, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+func (c *Client) groupURL(job string, grouping []Label) string {
+	u := strings.TrimSuffix(c.Url, "/") + "/metrics/" + segment("job", job)
+	for _, l := range grouping {
+		u += "/" + segment(l.Name, l.Value)
+	}
+
+	return u
+}
+
+// segment encodes a label for the URL path, values with a slash or empty
+// values need the base64 form.
+func segment(name, value string) string {
+	if value == "" || strings.Contains(value, "/") {
+		return name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+
+	return name + "/" + url.PathEscape(value)
+}
+
+// Format renders the gauges in the Prometheus text exposition format.
+func Format(gauges []Gauge) string {
+	var b strings.Builder
+	for _, g := range gauges {
+		if g.Help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", g.Name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(g.Help))
+		}
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", g.Name)
+		fmt.Fprintf(&b, "%s %s\n", g.Name, formatValue(g.Value))
+	}
+
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
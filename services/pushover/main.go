@@ -0,0 +1,156 @@
+package pushover
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Limits of a message in characters.
+const (
+	MessageLimit  = 1024
+	TitleLimit    = 250
+	UrlLimit      = 512
+	UrlTitleLimit = 100
+)
+
+// Priorities from -2 (no notification) to 2 (emergency, repeated until acknowledged).
+const (
+	PriorityLowest    = -2
+	PriorityLow       = -1
+	PriorityNormal    = 0
+	PriorityHigh      = 1
+	PriorityEmergency = 2
+)
+
+type Client struct {
+	ApiUrl string
+	Token  string
+}
+
+func NewClient(apiUrl, token string) *Client {
+	return &Client{
+		ApiUrl: apiUrl,
+		Token:  token,
+	}
+}
+
+type Message struct {
+	User     string
+	Message  string
+	Title    string
+	Devices  []string
+	Priority int
+	Url      string
+	UrlTitle string
+	// Retry and Expire are required for emergency priority.
+	Retry  time.Duration
+	Expire time.Duration
+	Html   bool
+}
+
+type response struct {
+	Status  int      `json:"status"`
+	Receipt string   `json:"receipt"`
+	Errors  []string `json:"errors"`
+}
+
+// Receipt tells whether an emergency message has been acknowledged.
+type Receipt struct {
+	Acknowledged   int    `json:"acknowledged"`
+	AcknowledgedAt int64  `json:"acknowledged_at"`
+	AcknowledgedBy string `json:"acknowledged_by"`
+	Expired        int    `json:"expired"`
+	ExpiresAt      int64  `json:"expires_at"`
+}
+
+// SendMessage sends the message and returns the receipt of emergency messages.
+func (c *Client) SendMessage(m *Message) (string, error) {
+	form := url.Values{}
+	form.Set("token", c.Token)
+	form.Set("user", m.User)
+	form.Set("message", truncate(m.Message, MessageLimit))
+	if m.Title != "" {
+		form.Set("title", truncate(m.Title, TitleLimit))
+	}
+	if len(m.Devices) > 0 {
+		form.Set("device", strings.Join(m.Devices, ","))
+	}
+	form.Set("priority", strconv.Itoa(m.Priority))
+	if m.Url != "" {
+		form.Set("url", truncate(m.Url, UrlLimit))
+	}
+	if m.UrlTitle != "" {
+		form.Set("url_title", truncate(m.UrlTitle, UrlTitleLimit))
+	}
+	if m.Priority == PriorityEmergency {
+		form.Set("retry", strconv.Itoa(int(m.Retry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(m.Expire.Seconds())))
+	}
+	if m.Html {
+		form.Set("html", "1")
+	}
+
+	slog.Debug("Sending message to pushover", "user", m.User, "priority", m.Priority)
+	resp, err := http.PostForm(strings.TrimSuffix(c.ApiUrl, "/")+"/1/messages.json", form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Status != 1 {
+		return "", fmt.Errorf("failed to send message to pushover, status: %s, errors: %s", resp.Status, strings.Join(result.Errors, ", "))
+	}
+
+	return result.Receipt, nil
+}
+
+func (c *Client) GetReceipt(receipt string) (*Receipt, error) {
+	resp, err := http.Get(strings.TrimSuffix(c.ApiUrl, "/") + "/1/receipts/" + url.PathEscape(receipt) + ".json?token=" + url.QueryEscape(c.Token))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		response
+		Receipt
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Status != 1 {
+		return nil, fmt.Errorf("failed to get receipt from pushover, status: %s, errors: %s", resp.Status, strings.Join(result.Errors, ", "))
+	}
+
+	return &result.Receipt, nil
+}
+
+// WaitForReceipt polls the receipt until the message is acknowledged or expired.
+func (c *Client) WaitForReceipt(receipt string, interval time.Duration) (*Receipt, error) {
+	for {
+		r, err := c.GetReceipt(receipt)
+		if err != nil {
+			return nil, err
+		}
+		if r.Acknowledged == 1 || r.Expired == 1 {
+			return r, nil
+		}
+
+		slog.Debug("Emergency message not acknowledged yet", "receipt", receipt)
+		time.Sleep(interval)
+	}
+}
+
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,194 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Format selects how a message is rendered in the webhook payload.
+type Format string
+
+var (
+	// FormatAttachments uses legacy attachments, understood by Slack,
+	// Mattermost and Rocket.Chat.
+	FormatAttachments Format = "attachments"
+	// FormatBlocks uses Block Kit inside a colored attachment, Slack only.
+	FormatBlocks Format = "blocks"
+)
+
+var (
+	ColorSuccess = "#2eb886"
+	ColorFailure = "#e01e5a"
+)
+
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type Message struct {
+	Title    string
+	Text     string
+	Color    string
+	Fields   []Field
+	Output   string
+	Url      string
+	Channel  string
+	Username string
+	Icon     string
+}
+
+type payload struct {
+	Text        string       `json:"text,omitempty"`
+	Channel     string       `json:"channel,omitempty"`
+	Username    string       `json:"username,omitempty"`
+	IconURL     string       `json:"icon_url,omitempty"`
+	IconEmoji   string       `json:"icon_emoji,omitempty"`
+	Attachments []attachment `json:"attachments,omitempty"`
+}
+
+type attachment struct {
+	Fallback  string   `json:"fallback,omitempty"`
+	Color     string   `json:"color,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	TitleLink string   `json:"title_link,omitempty"`
+	Text      string   `json:"text,omitempty"`
+	Fields    []Field  `json:"fields,omitempty"`
+	MrkdwnIn  []string `json:"mrkdwn_in,omitempty"`
+	Blocks    []any    `json:"blocks,omitempty"`
+}
+
+func SendMessage(m *Message, webhook string, format Format) error {
+	body, err := json.Marshal(m.payload(format))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("Sending request to slack webhook", "body", string(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send message to slack webhook, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}
+
+func (m *Message) payload(format Format) *payload {
+	p := &payload{
+		Text:     m.fallback(),
+		Channel:  m.Channel,
+		Username: m.Username,
+	}
+	if strings.HasPrefix(m.Icon, ":") {
+		p.IconEmoji = m.Icon
+	} else {
+		p.IconURL = m.Icon
+	}
+
+	if m.Color == "" && len(m.Fields) == 0 && m.Output == "" {
+		return p
+	}
+
+	a := attachment{
+		Fallback: m.fallback(),
+		Color:    m.Color,
+	}
+	if format == FormatBlocks {
+		a.Blocks = m.blocks()
+	} else {
+		a.Title = m.Title
+		a.TitleLink = m.Url
+		a.Text = m.Text
+		if m.Output != "" {
+			a.Text = strings.TrimPrefix(a.Text+"\n"+codeBlock(m.Output), "\n")
+		}
+		a.Fields = m.Fields
+		a.MrkdwnIn = []string{"text"}
+	}
+	p.Text = ""
+	p.Attachments = []attachment{a}
+
+	return p
+}
+
+func (m *Message) blocks() []any {
+	var blocks []any
+	if m.Title != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "header",
+			"text": map[string]string{"type": "plain_text", "text": truncateRunes(m.Title, maxHeaderLength)},
+		})
+	}
+	if m.Text != "" {
+		blocks = append(blocks, section(m.Text))
+	}
+	if len(m.Fields) > 0 {
+		var fields []map[string]string
+		for _, f := range m.Fields {
+			fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + f.Title + "*\n" + f.Value})
+		}
+		blocks = append(blocks, map[string]any{"type": "section", "fields": fields})
+	}
+	if m.Output != "" {
+		blocks = append(blocks, section(codeBlock(m.Output)))
+	}
+	if m.Url != "" {
+		blocks = append(blocks, section("<"+m.Url+">"))
+	}
+
+	return blocks
+}
+
+// maxHeaderLength is the longest text Slack accepts in a header block.
+const maxHeaderLength = 150
+
+// truncateRunes shortens s to at most max runes, ending with an ellipsis if
+// anything was cut.
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+
+	return string(runes[:max-1]) + "…"
+}
+
+func section(text string) map[string]any {
+	return map[string]any{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": text},
+	}
+}
+
+func codeBlock(s string) string {
+	return "```\n" + strings.TrimSuffix(s, "\n") + "\n```"
+}
+
+func (m *Message) fallback() string {
+	if m.Title != "" && m.Text != "" {
+		return m.Title + "\n" + m.Text
+	} else if m.Title != "" {
+		return m.Title
+	}
+
+	return m.Text
+}
@@ -0,0 +1,222 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rwxd/notify-me/services/ntfy"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+const timeout = 30 * time.Second
+
+// StructuredDataID is the SD-ID of the structured data element, the
+// private enterprise number is the one reserved for documentation.
+const StructuredDataID = "notify-me@32473"
+
+type Severity int
+
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+type Facility int
+
+const (
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityCron   Facility = 9
+	FacilityLocal0 Facility = 16
+)
+
+var facilities = map[string]Facility{
+	"user":   FacilityUser,
+	"daemon": FacilityDaemon,
+	"cron":   FacilityCron,
+	"local0": FacilityLocal0,
+	"local1": FacilityLocal0 + 1,
+	"local2": FacilityLocal0 + 2,
+	"local3": FacilityLocal0 + 3,
+	"local4": FacilityLocal0 + 4,
+	"local5": FacilityLocal0 + 5,
+	"local6": FacilityLocal0 + 6,
+	"local7": FacilityLocal0 + 7,
+}
+
+func ParseFacility(name string) (Facility, error) {
+	if f, ok := facilities[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+
+	return 0, fmt.Errorf("unknown facility %q", name)
+}
+
+// SeverityFromPriority maps the ntfy priority to a syslog severity, the
+// default priority is a notice.
+func SeverityFromPriority(p ntfy.Priority) Severity {
+	switch p.Level() {
+	case 1:
+		return SeverityDebug
+	case 2:
+		return SeverityInfo
+	case 4:
+		return SeverityWarning
+	case 5:
+		return SeverityCritical
+	default:
+		return SeverityNotice
+	}
+}
+
+// Message is a RFC 5424 message. StructuredData is written as single
+// element with the ID StructuredDataID.
+type Message struct {
+	Facility       Facility
+	Severity       Severity
+	Time           time.Time
+	Hostname       string
+	AppName        string
+	MsgID          string
+	StructuredData map[string]string
+	Message        string
+}
+
+// Format renders the message in the RFC 5424 format, without framing.
+func (m *Message) Format() string {
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		int(m.Facility)*8+int(m.Severity),
+		m.Time.Format(time.RFC3339Nano),
+		header(m.Hostname, 255),
+		header(m.AppName, 48),
+		os.Getpid(),
+		header(m.MsgID, 32),
+		m.structuredData(),
+		// BOM marks the message as UTF-8
+		"\ufeff"+m.Message,
+	)
+}
+
+func (m *Message) structuredData() string {
+	if len(m.StructuredData) == 0 {
+		return "-"
+	}
+
+	names := make([]string, 0, len(m.StructuredData))
+	for name := range m.StructuredData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("[" + StructuredDataID)
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=\"%s\"", name, escapeParam(m.StructuredData[name]))
+	}
+	b.WriteString("]")
+
+	return b.String()
+}
+
+// header replaces characters not allowed in header fields and truncates them
+// to max, empty fields are the nil value "-".
+func header(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	} else if len(s) > max {
+		return s[:max]
+	}
+
+	return s
+}
+
+func escapeParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// Send delivers the message to the server. The address is a URL with the
+// scheme unix, udp, tcp or tls, e.g. "udp://logs:514" or "unix:///dev/log".
+// Messages over TCP and TLS are framed with octet counting (RFC 6587).
+func Send(address string, m *Message, tlsConfig *tls.Config) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial(u, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	msg := m.Format()
+	if u.Scheme == "tcp" || u.Scheme == "tls" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	slog.Debug("Sending syslog message", "address", address, "message", msg)
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("failed to send syslog message to %s: %w", address, err)
+	}
+
+	return nil
+}
+
+func dial(u *url.URL, tlsConfig *tls.Config) (net.Conn, error) {
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		// /dev/log is a datagram socket on most systems, some daemons only
+		// listen on stream sockets
+		conn, err := net.DialTimeout("unixgram", filepath.Clean(path), timeout)
+		if err != nil {
+			return net.DialTimeout("unix", filepath.Clean(path), timeout)
+		}
+		return conn, nil
+	case "udp", "tcp":
+		return net.DialTimeout(u.Scheme, hostPort(u, "514"), timeout)
+	case "tls":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = u.Hostname()
+		}
+		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", hostPort(u, "6514"), tlsConfig)
+	default:
+		return nil, errors.New("scheme must be unix, udp, tcp or tls")
+	}
+}
+
+func hostPort(u *url.URL, port string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return u.Host
+}
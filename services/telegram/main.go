@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// Limits of the Bot API in characters.
+const (
+	MessageLimit = 4096
+	CaptionLimit = 1024
+)
+
+type ParseMode string
+
+var (
+	ParseModeNone       ParseMode = ""
+	ParseModeHTML       ParseMode = "HTML"
+	ParseModeMarkdownV2 ParseMode = "MarkdownV2"
+)
+
+type Bot struct {
+	ApiUrl string
+	Token  string
+}
+
+func NewBot(apiUrl, token string) *Bot {
+	return &Bot{
+		ApiUrl: apiUrl,
+		Token:  token,
+	}
+}
+
+type Message struct {
+	ChatID                string    `json:"chat_id"`
+	Text                  string    `json:"text"`
+	ParseMode             ParseMode `json:"parse_mode,omitempty"`
+	DisableNotification   bool      `json:"disable_notification,omitempty"`
+	DisableWebPagePreview bool      `json:"disable_web_page_preview,omitempty"`
+}
+
+type Document struct {
+	ChatID              string
+	FileName            string
+	Data                []byte
+	Caption             string
+	ParseMode           ParseMode
+	DisableNotification bool
+}
+
+func (b *Bot) SendMessage(m *Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Sending message to telegram", "body", string(body))
+	return b.call("sendMessage", "application/json", body)
+}
+
+func (b *Bot) SendDocument(d *Document) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", d.ChatID)
+	if d.Caption != "" {
+		w.WriteField("caption", d.Caption)
+	}
+	if d.ParseMode != ParseModeNone {
+		w.WriteField("parse_mode", string(d.ParseMode))
+	}
+	w.WriteField("disable_notification", strconv.FormatBool(d.DisableNotification))
+	part, err := w.CreateFormFile("document", d.FileName)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(d.Data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	slog.Debug("Sending document to telegram", "chat", d.ChatID, "file", d.FileName, "size", len(d.Data))
+	return b.call("sendDocument", w.FormDataContentType(), body.Bytes())
+}
+
+func (b *Bot) call(method, contentType string, body []byte) error {
+	url := strings.TrimSuffix(b.ApiUrl, "/") + "/bot" + b.Token + "/" + method
+
+	resp, err := retry.Default.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	if err != nil {
+		// don't leak the bot token in the URL of the error
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to call telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, &result); err != nil || !result.Ok {
+		if result.Description == "" {
+			result.Description = string(data)
+		}
+		return fmt.Errorf("failed to call telegram %s, status: %s, description: %s", method, resp.Status, result.Description)
+	}
+
+	return nil
+}
+
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// EscapeMarkdownV2 escapes text so it is shown literally with MarkdownV2.
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Replacer.Replace(s)
+}
+
+var markdownV2CodeReplacer = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// EscapeMarkdownV2Code escapes text inside a MarkdownV2 code block.
+func EscapeMarkdownV2Code(s string) string {
+	return markdownV2CodeReplacer.Replace(s)
+}
+
+var htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EscapeHTML escapes text so it is shown literally with the HTML parse mode.
+func EscapeHTML(s string) string {
+	return htmlReplacer.Replace(s)
+}
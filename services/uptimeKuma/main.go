@@ -1,17 +1,66 @@
 package uptimekuma
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sagikazarmark/slog-shim"
 )
 
-func SendMonitorStatus(instance, token string, up bool, message string, ping string) error {
-	status := "up"
+// Status is the state a push monitor is set to.
+type Status string
+
+var (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// PushRequest is a single heartbeat for a push monitor.
+type PushRequest struct {
+	Status Status
+	Msg    string
+	// Ping is the measured value in milliseconds, nil if nothing was measured.
+	Ping *float64
+}
+
+func NewPushRequest(up bool, msg string) *PushRequest {
+	status := StatusUp
 	if !up {
-		status = "down"
+		status = StatusDown
+	}
+
+	return &PushRequest{
+		Status: status,
+		Msg:    msg,
+	}
+}
+
+// WithPing sets the measured ping in milliseconds.
+func (p *PushRequest) WithPing(ms float64) *PushRequest {
+	p.Ping = &ms
+	return p
+}
+
+func (p *PushRequest) validate() error {
+	if p.Status != StatusUp && p.Status != StatusDown {
+		return fmt.Errorf("invalid status %q, must be %q or %q", p.Status, StatusUp, StatusDown)
+	}
+	if p.Ping != nil && (math.IsNaN(*p.Ping) || math.IsInf(*p.Ping, 0) || *p.Ping < 0) {
+		return fmt.Errorf("invalid ping %v, must be a positive number of milliseconds", *p.Ping)
+	}
+
+	return nil
+}
+
+func SendPush(instance, token string, p *PushRequest) error {
+	if err := p.validate(); err != nil {
+		return err
 	}
 
 	req, err := http.NewRequest("GET", strings.TrimSuffix(instance, "/")+"/api/push/"+token, nil)
@@ -20,12 +69,12 @@ func SendMonitorStatus(instance, token string, up bool, message string, ping str
 	}
 
 	q := req.URL.Query()
-	q.Add("status", status)
-	if message != "" {
-		q.Add("msg", message)
+	q.Add("status", string(p.Status))
+	if p.Msg != "" {
+		q.Add("msg", p.Msg)
 	}
-	if ping != "" {
-		q.Add("ping", ping)
+	if p.Ping != nil {
+		q.Add("ping", strconv.FormatFloat(*p.Ping, 'f', -1, 64))
 	}
 	req.URL.RawQuery = q.Encode()
 
@@ -35,6 +84,7 @@ func SendMonitorStatus(instance, token string, up bool, message string, ping str
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body := make([]byte, 1024)
@@ -44,3 +94,36 @@ func SendMonitorStatus(instance, token string, up bool, message string, ping str
 
 	return nil
 }
+
+// PingFromDuration converts a measured duration to the milliseconds uptime-kuma expects.
+func PingFromDuration(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// ParsePing extracts a ping in milliseconds from the output of a measurement
+// command. Without a pattern the whole trimmed output must be a number, with
+// a pattern the first capture group (or the whole match) is used.
+func ParsePing(output string, pattern *regexp.Regexp) (float64, error) {
+	value := strings.TrimSpace(output)
+	if pattern != nil {
+		match := pattern.FindStringSubmatch(output)
+		if match == nil {
+			return 0, fmt.Errorf("ping pattern %q did not match the output", pattern.String())
+		}
+		value = match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+	}
+
+	if value == "" {
+		return 0, errors.New("no ping value found in the output")
+	}
+
+	ping, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ping %q is not a number", value)
+	}
+
+	return ping, nil
+}
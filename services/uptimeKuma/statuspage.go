@@ -0,0 +1,178 @@
+package uptimekuma
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sagikazarmark/slog-shim"
+)
+
+// MonitorState is the state of a monitor as reported by heartbeats.
+type MonitorState int
+
+const (
+	StateDown MonitorState = iota
+	StateUp
+	StatePending
+	StateMaintenance
+)
+
+func (s MonitorState) String() string {
+	switch s {
+	case StateDown:
+		return "down"
+	case StateUp:
+		return "up"
+	case StatePending:
+		return "pending"
+	case StateMaintenance:
+		return "maintenance"
+	}
+
+	return "unknown"
+}
+
+type Heartbeat struct {
+	Status MonitorState `json:"status"`
+	Time   string       `json:"time"`
+	Msg    string       `json:"msg"`
+	Ping   *float64     `json:"ping"`
+}
+
+type Monitor struct {
+	ID    int
+	Name  string
+	Group string
+	Type  string
+	// Heartbeat is the latest heartbeat, nil if the monitor has none yet.
+	Heartbeat *Heartbeat
+	// Uptime is the uptime of the last 24 hours between 0 and 1.
+	Uptime float64
+}
+
+// State returns the state of the latest heartbeat, pending without heartbeats.
+func (m *Monitor) State() MonitorState {
+	if m.Heartbeat == nil {
+		return StatePending
+	}
+
+	return m.Heartbeat.Status
+}
+
+type Maintenance struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// StatusPage is the public data of a status page.
+type StatusPage struct {
+	Slug         string
+	Title        string
+	Monitors     []Monitor
+	Maintenances []Maintenance
+}
+
+// Monitor looks up a monitor by name or ID.
+func (p *StatusPage) Monitor(nameOrID string) *Monitor {
+	for i := range p.Monitors {
+		if p.Monitors[i].Name == nameOrID || strconv.Itoa(p.Monitors[i].ID) == nameOrID {
+			return &p.Monitors[i]
+		}
+	}
+
+	return nil
+}
+
+// InMaintenance reports whether a maintenance is active for the monitor. Without
+// a monitor any active maintenance on the status page counts.
+func (p *StatusPage) InMaintenance(monitor string) (bool, error) {
+	if monitor == "" {
+		return len(p.Maintenances) > 0, nil
+	}
+
+	m := p.Monitor(monitor)
+	if m == nil {
+		return false, fmt.Errorf("monitor %q not found on status page %q", monitor, p.Slug)
+	}
+
+	return m.State() == StateMaintenance, nil
+}
+
+type statusPageResponse struct {
+	Config struct {
+		Title string `json:"title"`
+	} `json:"config"`
+	PublicGroupList []struct {
+		Name        string `json:"name"`
+		MonitorList []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"monitorList"`
+	} `json:"publicGroupList"`
+	MaintenanceList []Maintenance `json:"maintenanceList"`
+}
+
+type heartbeatResponse struct {
+	HeartbeatList map[string][]Heartbeat `json:"heartbeatList"`
+	UptimeList    map[string]float64     `json:"uptimeList"`
+}
+
+// GetStatusPage reads the public status page and heartbeat data for slug.
+func GetStatusPage(instance, slug string) (*StatusPage, error) {
+	instance = strings.TrimSuffix(instance, "/")
+
+	var page statusPageResponse
+	if err := getJSON(instance+"/api/status-page/"+slug, &page); err != nil {
+		return nil, err
+	}
+
+	var heartbeats heartbeatResponse
+	if err := getJSON(instance+"/api/status-page/heartbeat/"+slug, &heartbeats); err != nil {
+		return nil, err
+	}
+
+	statusPage := &StatusPage{
+		Slug:         slug,
+		Title:        page.Config.Title,
+		Maintenances: page.MaintenanceList,
+	}
+	for _, group := range page.PublicGroupList {
+		for _, m := range group.MonitorList {
+			monitor := Monitor{
+				ID:     m.ID,
+				Name:   m.Name,
+				Group:  group.Name,
+				Type:   m.Type,
+				Uptime: heartbeats.UptimeList[strconv.Itoa(m.ID)+"_24"],
+			}
+			if list := heartbeats.HeartbeatList[strconv.Itoa(m.ID)]; len(list) > 0 {
+				monitor.Heartbeat = &list[len(list)-1]
+			}
+			statusPage.Monitors = append(statusPage.Monitors, monitor)
+		}
+	}
+
+	return statusPage, nil
+}
+
+func getJSON(url string, v any) error {
+	slog.Debug("Sending request to uptime-kuma", "url", url)
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to read status page from uptime-kuma, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
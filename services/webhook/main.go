@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rwxd/notify-me/internal/retry"
+	"github.com/sagikazarmark/slog-shim"
+)
+
+type Request struct {
+	Url     string
+	Method  string
+	Headers http.Header
+	Body    string
+}
+
+func NewRequest(url, method string, headers http.Header, body string) *Request {
+	return &Request{
+		Url:     url,
+		Method:  method,
+		Headers: headers,
+		Body:    body,
+	}
+}
+
+// Send sends the request, retrying transient failures with the policy. Every
+// 2xx status counts as success.
+func Send(r *Request, policy retry.Policy) error {
+	slog.Debug("Sending webhook", "method", r.Method, "url", r.Url, "headers", r.Headers, "body", r.Body)
+	resp, err := policy.Do(nil, func() (*http.Request, error) {
+		req, err := http.NewRequest(r.Method, r.Url, strings.NewReader(r.Body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range r.Headers {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body := make([]byte, 1024)
+		n, _ := resp.Body.Read(body)
+		return fmt.Errorf("failed to send webhook, status: %s, body: %s", string(resp.Status), string(body[:n]))
+	}
+
+	return nil
+}